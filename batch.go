@@ -0,0 +1,297 @@
+package squirrel2
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// BatchError is returned by a Batch when one of its statements fails.
+type BatchError struct {
+	// Index is the index of the statement that failed in the Batch, or -1
+	// if it's unknown, e.g. when a multi-statement batch fails in the
+	// database.
+	Index int
+	// SQL is the SQL of the statement that failed, if known.
+	SQL string
+	// Err is the error the statement failed with.
+	Err error
+}
+
+func (e *BatchError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("batch failed: %v", e.Err)
+	}
+	return fmt.Sprintf("batch statement %d failed: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Batch runs several statements together: as one multi-statement string if
+// MultiStatement is set, or one after the other in a transaction.
+//
+// The statements keep their tags, and their Timeout when they are run one
+// after the other.
+//
+// Ex:
+//
+//	results, err := NewBatch(
+//		Insert("users").Columns("name").Values("ann"),
+//		Update("stats").Set("users", Expr("users + 1")),
+//	).PlaceholderFormat(Dollar).RunWith(db).ExecContext(ctx)
+type Batch struct {
+	placeholderFormat PlaceholderFormat
+	runWith           BaseRunner
+	statements        []Sqlizer
+	multiStatement    bool
+}
+
+// NewBatch returns a Batch of statements.
+func NewBatch(statements ...Sqlizer) Batch {
+	return StatementBuilder.Batch(statements...)
+}
+
+// Batch returns a Batch of statements for this StatementBuilderType. Like
+// NewBatch, it takes the PlaceholderFormat of its statements.
+func (b statementBuilderType) Batch(statements ...Sqlizer) Batch {
+	return Batch{runWith: b.runWith}.Add(statements...)
+}
+
+// Add adds statements to the batch.
+func (b Batch) Add(statements ...Sqlizer) Batch {
+	b.statements = append(b.statements[:len(b.statements):len(b.statements)], statements...)
+	return b
+}
+
+// PlaceholderFormat sets PlaceholderFormat (e.g. Question or Dollar) for the
+// statements of the batch, replacing their own.
+//
+// Batches without one use the PlaceholderFormat of their statements, which
+// must then all have the same, or Question if none of them is a builder of
+// this package.
+func (b Batch) PlaceholderFormat(f PlaceholderFormat) Batch {
+	b.placeholderFormat = f
+	return b
+}
+
+// RunWith sets the Runner the batch is run with. Runners that can begin
+// transactions (e.g. *sql.DB) run the statements of the batch in one; others
+// (e.g. *sql.Tx) run them in the transaction they belong to, if any.
+func (b Batch) RunWith(runner BaseRunner) Batch {
+	b.runWith = runner
+	return b
+}
+
+// MultiStatement makes the batch run as one multi-statement string, in a
+// single round trip. It requires a driver accepting several statements, and
+// their args, in one Exec.
+//
+// As the driver doesn't tell which statement failed, the BatchError of a
+// failure in the database has an Index of -1. Statements can't have their own
+// Timeout; the deadline of the context of ExecContext applies to the whole
+// batch instead.
+func (b Batch) MultiStatement() Batch {
+	b.multiStatement = true
+	return b
+}
+
+// ToSql builds the statements of the batch into one multi-statement string,
+// separated by semicolons on lines of their own, and its args. The newline
+// before each semicolon ends a trailing line comment of the statement before
+// it, e.g. a Suffix("-- note"), which would comment out the separator.
+// Placeholders are numbered across statements, e.g. for Dollar the first
+// placeholder of the second statement follows the last one of the first.
+func (b Batch) ToSql() (string, []interface{}, error) {
+	f, err := b.format()
+	if err != nil {
+		return "", nil, err
+	}
+	sqls := make([]string, len(b.statements))
+	var args []interface{}
+	for i, s := range b.statements {
+		sql, stmtArgs, err := nestedToSql(s)
+		if err != nil {
+			return "", nil, &BatchError{Index: i, Err: err}
+		}
+		sqls[i] = sql
+		args = append(args, stmtArgs...)
+	}
+	return replacePlaceholders(f, strings.Join(sqls, "\n;\n"), args)
+}
+
+// format returns the PlaceholderFormat of the batch, as set by
+// PlaceholderFormat or else shared by its statements.
+func (b Batch) format() (PlaceholderFormat, error) {
+	if b.placeholderFormat != nil {
+		return b.placeholderFormat, nil
+	}
+	var (
+		f     PlaceholderFormat
+		first int
+	)
+	for i, s := range b.statements {
+		sf := statementPlaceholderFormat(s)
+		if sf == nil {
+			continue
+		}
+		if f == nil {
+			f, first = sf, i
+			continue
+		}
+		if !samePlaceholderFormat(f, sf) {
+			return nil, &BatchError{Index: i, Err: fmt.Errorf(
+				"placeholder format %T differs from %T of statement %d; set one with Batch.PlaceholderFormat", sf, f, first)}
+		}
+	}
+	if f == nil {
+		return Question, nil
+	}
+	return f, nil
+}
+
+// Exec runs the batch with context.Background(). See ExecContext.
+func (b Batch) Exec() ([]sql.Result, error) {
+	return b.ExecContext(context.Background())
+}
+
+// ExecContext runs the batch, returning the sql.Result of each statement.
+// A multi-statement batch returns the single sql.Result of the whole batch
+// instead. Failures are returned as a *BatchError.
+func (b Batch) ExecContext(ctx context.Context) ([]sql.Result, error) {
+	runner := b.runWith
+	if r, ok := runner.(*stdsqlCtxRunner); ok {
+		// As set by StatementBuilder.RunWith, which hides BeginTx.
+		runner = r.StdSqlCtx
+	}
+	if runner == nil {
+		return nil, ErrRunnerNotSet
+	}
+	db, ok := runner.(ExecerContext)
+	if !ok {
+		return nil, ErrNoContextSupport
+	}
+	if len(b.statements) == 0 {
+		return nil, nil
+	}
+
+	if b.multiStatement {
+		for i, s := range b.statements {
+			if statementTimeout(s) > 0 {
+				return nil, &BatchError{Index: i, Err: errors.New("a statement of a multi-statement batch can't have a Timeout")}
+			}
+		}
+		query, args, err := b.ToSql()
+		if err != nil {
+			return nil, err
+		}
+		res, err := db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return nil, &BatchError{Index: -1, SQL: query, Err: err}
+		}
+		return []sql.Result{res}, nil
+	}
+
+	f, err := b.format()
+	if err != nil {
+		return nil, err
+	}
+	queries := make([]string, len(b.statements))
+	args := make([][]interface{}, len(b.statements))
+	timeouts := make([]time.Duration, len(b.statements))
+	for i, s := range b.statements {
+		timeouts[i] = statementTimeout(s)
+		sql, stmtArgs, err := nestedToSql(s)
+		if err == nil {
+			sql, stmtArgs, err = replacePlaceholders(f, sql, stmtArgs)
+		}
+		if err != nil {
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		queries[i], args[i] = sql, stmtArgs
+	}
+	var results []sql.Result
+	run := func(tx ExecerContext) error {
+		results = make([]sql.Result, len(queries))
+		for i, query := range queries {
+			res, err := execBatchStatement(ctx, tx, timeouts[i], query, args[i])
+			if err != nil {
+				return &BatchError{Index: i, SQL: query, Err: err}
+			}
+			results[i] = res
+		}
+		return nil
+	}
+
+	switch r := runner.(type) {
+	case TxBeginner:
+		err = WithTx(ctx, r, nil, func(tx RunnerContext) error { return run(tx) })
+	case *Tx:
+		err = r.WithTx(ctx, func(tx RunnerContext) error { return run(tx) })
+	default:
+		err = run(db)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// execBatchStatement runs a statement of a batch with tx, within timeout if
+// it's positive.
+func execBatchStatement(ctx context.Context, tx ExecerContext, timeout time.Duration, query string, args []interface{}) (sql.Result, error) {
+	if timeout <= 0 {
+		return tx.ExecContext(ctx, query, args...)
+	}
+	ctx, cancel, cause := withTimeout(ctx, timeout)
+	defer cancel()
+	res, err := tx.ExecContext(ctx, query, args...)
+	return res, timeoutErr(ctx, cause, err)
+}
+
+// statementPlaceholderFormat returns the PlaceholderFormat of s, if it's a
+// builder of this package.
+func statementPlaceholderFormat(s Sqlizer) PlaceholderFormat {
+	switch b := s.(type) {
+	case selectBuilder:
+		return b.data.PlaceholderFormat
+	case insertBuilder:
+		return b.data.PlaceholderFormat
+	case updateBuilder:
+		return b.data.PlaceholderFormat
+	case deleteBuilder:
+		return b.data.PlaceholderFormat
+	}
+	return nil
+}
+
+// samePlaceholderFormat reports whether a and b are the same
+// PlaceholderFormat, without panicking on formats that aren't comparable.
+func samePlaceholderFormat(a, b PlaceholderFormat) bool {
+	ta, tb := reflect.TypeOf(a), reflect.TypeOf(b)
+	if ta != tb {
+		return false
+	}
+	return !ta.Comparable() || a == b
+}
+
+// statementTimeout returns the Timeout of s, if it's a builder of this
+// package.
+func statementTimeout(s Sqlizer) time.Duration {
+	switch b := s.(type) {
+	case selectBuilder:
+		return b.data.Timeout
+	case insertBuilder:
+		return b.data.Timeout
+	case updateBuilder:
+		return b.data.Timeout
+	case deleteBuilder:
+		return b.data.Timeout
+	}
+	return 0
+}
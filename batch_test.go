@@ -0,0 +1,222 @@
+package squirrel2
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchToSql(t *testing.T) {
+	b := NewBatch(
+		Insert("users").Columns("name", "age").Values("ann", 30),
+		Update("users").Set("age", 31).Where(Eq{"name": "ann"}),
+	).Add(Delete("sessions").Where(Lt{"expires": 5})).PlaceholderFormat(Dollar)
+
+	sql, args, err := b.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"INSERT INTO users (name,age) VALUES ($1,$2)\n;\n"+
+			"UPDATE users SET age = $3 WHERE name = $4\n;\n"+
+			"DELETE FROM sessions WHERE expires < $5",
+		sql)
+	assert.Equal(t, []interface{}{"ann", 30, 31, "ann", 5}, args)
+
+	_, _, err = NewBatch(Delete("t"), Insert("t")).ToSql()
+	var batchErr *BatchError
+	if assert.ErrorAs(t, err, &batchErr) {
+		assert.Equal(t, 1, batchErr.Index)
+		assert.Equal(t, "batch statement 1 failed: insert statements must have at least one set of values or select clause", err.Error())
+	}
+}
+
+func TestBatchToSqlStatementFormat(t *testing.T) {
+	sql, args, err := NewBatch(
+		Update("t").Set("a", 1).PlaceholderFormat(Dollar),
+		Expr("NOTIFY t"),
+		Delete("t").Where(Eq{"a": 2}).PlaceholderFormat(Dollar),
+	).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE t SET a = $1\n;\nNOTIFY t\n;\nDELETE FROM t WHERE a = $2", sql)
+	assert.Equal(t, []interface{}{1, 2}, args)
+
+	sql, _, err = NewBatch(Expr("SELECT ?", 1)).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT ?", sql)
+
+	_, _, err = NewBatch(Update("t").Set("a", 1).PlaceholderFormat(Dollar), Delete("t").Where(Eq{"a": 2})).ToSql()
+	var batchErr *BatchError
+	if assert.ErrorAs(t, err, &batchErr) {
+		assert.Equal(t, 1, batchErr.Index)
+		assert.EqualError(t, err, "batch statement 1 failed: placeholder format squirrel2.questionFormat "+
+			"differs from squirrel2.dollarFormat of statement 0; set one with Batch.PlaceholderFormat")
+	}
+	db, _ := newFakeDB(fakeResult{})
+	_, err = NewBatch(Update("t").Set("a", 1).PlaceholderFormat(Dollar), Delete("t")).RunWith(db).Exec()
+	assert.ErrorAs(t, err, &batchErr)
+}
+
+func TestBatchToSqlLineComment(t *testing.T) {
+	sql, args, err := NewBatch(
+		Update("t").Set("a", 1).Suffix("-- note"),
+		Delete("t").Where(Eq{"a": 2}),
+	).PlaceholderFormat(Dollar).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE t SET a = $1 -- note\n;\nDELETE FROM t WHERE a = $2", sql)
+	assert.Equal(t, []interface{}{1, 2}, args)
+
+	// The lexer sees the second statement, and its placeholder, after the
+	// comment.
+	count := 0
+	assert.NoError(t, sqlLexer{positional: "$"}.scan(sql, func(tok sqlToken) error {
+		if tok.kind == sqlPositional {
+			count++
+		}
+		return nil
+	}))
+	assert.Equal(t, 2, count)
+}
+
+func TestBatchExec(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	b := NewBatch(
+		Insert("users").Columns("name").Values("ann"),
+		Update("users").Set("age", 31).Where(Eq{"name": "ann"}),
+	).PlaceholderFormat(Dollar).RunWith(db)
+
+	results, err := b.ExecContext(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	// Each statement is numbered on its own.
+	assert.Equal(t, []string{
+		"BEGIN",
+		"INSERT INTO users (name) VALUES ($1)",
+		"UPDATE users SET age = $1 WHERE name = $2",
+		"COMMIT",
+	}, fake.statements())
+}
+
+func TestBatchExecFailure(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	boom := errors.New("boom")
+	fake.execErr = func(query string) error {
+		if strings.HasPrefix(query, "UPDATE") {
+			return boom
+		}
+		return nil
+	}
+
+	_, err := NewBatch(Insert("t").Values(1), Update("t").Set("x", 2), Delete("t")).RunWith(db).Exec()
+	var batchErr *BatchError
+	if assert.ErrorAs(t, err, &batchErr) {
+		assert.Equal(t, 1, batchErr.Index)
+		assert.Equal(t, "UPDATE t SET x = ?", batchErr.SQL)
+		assert.ErrorIs(t, err, boom)
+	}
+	assert.Equal(t, []string{"BEGIN", "INSERT INTO t VALUES (?)", "UPDATE t SET x = ?", "ROLLBACK"}, fake.statements())
+
+	// Statements that fail to build aren't run.
+	_, err = NewBatch(Delete("t"), Insert("t")).RunWith(db).Exec()
+	assert.ErrorAs(t, err, &batchErr)
+	assert.Len(t, fake.statements(), 4)
+}
+
+func TestBatchMultiStatement(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	sb := StatementBuilder.PlaceholderFormat(Dollar).RunWith(db)
+	b := sb.Batch(sb.Insert("t").Values(1), sb.Update("t").Set("x", 2)).MultiStatement()
+
+	results, err := b.ExecContext(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, []string{"INSERT INTO t VALUES ($1)\n;\nUPDATE t SET x = $2"}, fake.statements())
+
+	boom := errors.New("boom")
+	fake.execErr = func(string) error { return boom }
+	_, err = b.ExecContext(context.Background())
+	var batchErr *BatchError
+	if assert.ErrorAs(t, err, &batchErr) {
+		assert.Equal(t, -1, batchErr.Index)
+		assert.Equal(t, "batch failed: boom", err.Error())
+	}
+}
+
+// blockingDeleteU runs "DELETE FROM u" until its context is done, and other
+// statements at once.
+type blockingDeleteU struct{ blockingRunner }
+
+func (r blockingDeleteU) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if query != "DELETE FROM u" {
+		return driver.RowsAffected(0), nil
+	}
+	return r.blockingRunner.ExecContext(ctx, query, args...)
+}
+
+func TestBatchStatementOptions(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	b := NewBatch(Insert("t").Values(1).Tag("n", "1"), Delete("t").Timeout(time.Minute))
+
+	_, err := b.RunWith(db).Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"BEGIN", "INSERT INTO t VALUES (?) /*n='1'*/", "DELETE FROM t", "COMMIT"}, fake.statements())
+
+	_, err = NewBatch(Delete("t"), Delete("u").Timeout(10*time.Millisecond)).RunWith(blockingDeleteU{}).Exec()
+	var batchErr *BatchError
+	var timeoutErr *TimeoutError
+	if assert.ErrorAs(t, err, &batchErr) && assert.ErrorAs(t, err, &timeoutErr) {
+		assert.Equal(t, 1, batchErr.Index)
+		assert.Equal(t, 10*time.Millisecond, timeoutErr.Timeout)
+	}
+
+	fake.log = nil
+	_, err = b.RunWith(db).MultiStatement().Exec()
+	if assert.ErrorAs(t, err, &batchErr) {
+		assert.Equal(t, 1, batchErr.Index)
+		assert.Equal(t, "batch statement 1 failed: a statement of a multi-statement batch can't have a Timeout", err.Error())
+	}
+	assert.Empty(t, fake.statements())
+}
+
+func TestBatchInTx(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	b := NewBatch(Insert("t").Values(1), Delete("t"))
+
+	// Batches run with a StatementBuilder's runner still begin a transaction.
+	_, err := StatementBuilder.RunWith(db).Batch(Delete("t")).Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"BEGIN", "DELETE FROM t", "COMMIT"}, fake.statements())
+
+	fake.log = nil
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+	_, err = b.RunWith(tx).Exec()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+	assert.Equal(t, []string{"BEGIN", "INSERT INTO t VALUES (?)", "DELETE FROM t", "COMMIT"}, fake.statements())
+
+	fake.log = nil
+	err = WithTx(context.Background(), db, nil, func(tx RunnerContext) error {
+		_, err := b.RunWith(tx).Exec()
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"BEGIN",
+		"SAVEPOINT sp_1",
+		"INSERT INTO t VALUES (?)",
+		"DELETE FROM t",
+		"RELEASE SAVEPOINT sp_1",
+		"COMMIT",
+	}, fake.statements())
+
+	_, err = NewBatch(Delete("t")).Exec()
+	assert.Equal(t, ErrRunnerNotSet, err)
+	results, err := NewBatch().RunWith(db).Exec()
+	assert.NoError(t, err)
+	assert.Nil(t, results)
+}
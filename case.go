@@ -94,6 +94,10 @@ func CaseBuilder() caseBuilder {
 
 // ToSql builds the query into a SQL string and bound args.
 func (b caseBuilder) ToSql() (string, []interface{}, error) {
+	return resolveNamedArgs(b.data.ToSql())
+}
+
+func (b caseBuilder) toSqlRaw() (string, []interface{}, error) {
 	return b.data.ToSql()
 }
 
@@ -0,0 +1,91 @@
+package squirrel2
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ColumnsOf returns the columns matching the db tags of the struct type T (or
+// *T), in field order, as ScanAll maps them. If alias isn't empty, each column
+// is qualified with it.
+//
+// Ex:
+//
+//	columns, err := ColumnsOf[User]("u")
+//	if err != nil {
+//		return err
+//	}
+//	Select(columns...).From("users u")
+//	// SELECT u.id, u.name FROM users u
+//
+// Tags must be plain identifiers (letters, digits and underscores, not
+// starting with a digit) so that the columns are safe to interpolate;
+// ColumnsOf returns an error if one isn't, or if T isn't a struct.
+func ColumnsOf[T any](alias safeString) ([]safeString, error) {
+	return columnsOf[T](alias, "")
+}
+
+// ColumnsOfAs is like ColumnsOf, but names each column after the db tag
+// prefixed with prefix and an underscore, e.g. "u.id AS u_id". It tells apart
+// the columns of joined tables that share names, so that they can be scanned
+// into structs tagged with the prefixed names.
+//
+// Ex:
+//
+//	userColumns, err := ColumnsOfAs[User]("u", "user")
+//	...
+//	teamColumns, err := ColumnsOfAs[Team]("t", "team")
+//	...
+//	Select(append(userColumns, teamColumns...)...).From("users u").Join("teams t ON t.id = u.team_id")
+//	// SELECT u.id AS user_id, u.name AS user_name, t.id AS team_id, t.name AS team_name FROM ...
+//
+// The prefix must be a plain identifier, like the tags.
+func ColumnsOfAs[T any](alias, prefix safeString) ([]safeString, error) {
+	if !isPlainIdent(string(prefix)) {
+		return nil, fmt.Errorf("column prefix %q is not a plain identifier", prefix)
+	}
+	return columnsOf[T](alias, prefix)
+}
+
+func columnsOf[T any](alias, prefix safeString) ([]safeString, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if !isMappedStruct(t) {
+		return nil, fmt.Errorf("cannot derive columns from %s; it isn't a struct", t)
+	}
+
+	fields := structFieldsOf(t)
+	columns := make([]safeString, len(fields.names))
+	for i, name := range fields.names {
+		if !isPlainIdent(name) {
+			return nil, fmt.Errorf("db tag %q of %s is not a plain identifier", name, t)
+		}
+		//squirrelvet:allow checked by isPlainIdent
+		column := safeString(name)
+		columns[i] = column
+		if alias != "" {
+			columns[i] = alias + "." + columns[i]
+		}
+		if prefix != "" {
+			columns[i] += " AS " + prefix + "_" + column
+		}
+	}
+	return columns, nil
+}
+
+// isPlainIdent reports whether s is an identifier that doesn't need quoting.
+func isPlainIdent(s string) bool {
+	if s == "" || len(s) > maxIdentLength {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '_' && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') &&
+			(i == 0 || !('0' <= c && c <= '9')) {
+			return false
+		}
+	}
+	return true
+}
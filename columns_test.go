@@ -0,0 +1,143 @@
+package squirrel2
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColumnsOf(t *testing.T) {
+	expected := []safeString{"id", "name", "email", "age", "created_at", "deleted_at"}
+	columns, err := ColumnsOf[scanUser]("")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, columns)
+	columns, err = ColumnsOf[*scanUser]("")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, columns)
+
+	columns, err = ColumnsOf[scanUser]("u")
+	assert.NoError(t, err)
+	sql, _, err := Select(columns...).From("users u").ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"SELECT u.id, u.name, u.email, u.age, u.created_at, u.deleted_at FROM users u", sql)
+}
+
+func TestColumnsOfShadowedField(t *testing.T) {
+	type Base struct {
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	type Item struct {
+		Base
+		Name string `db:"name"`
+		Code string `db:"code"`
+	}
+	columns, err := ColumnsOf[Item]("")
+	assert.NoError(t, err)
+	assert.Equal(t, []safeString{"id", "name", "code"}, columns)
+}
+
+func TestColumnsOfAmbiguousField(t *testing.T) {
+	type Audit struct {
+		UpdatedAt time.Time `db:"updated_at"`
+	}
+	type Sync struct {
+		UpdatedAt time.Time `db:"updated_at"`
+	}
+	type Item struct {
+		ID int64 `db:"id"`
+		Audit
+		Sync
+	}
+	columns, err := ColumnsOf[Item]("")
+	assert.NoError(t, err)
+	assert.Equal(t, []safeString{"id"}, columns)
+}
+
+func TestColumnsOfScanRoundTrip(t *testing.T) {
+	columns, err := ColumnsOf[scanUser]("")
+	assert.NoError(t, err)
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = string(c)
+	}
+	db, _ := newFakeDB(fakeResult{
+		columns: names,
+		rows:    [][]driver.Value{{int64(1), "ann", nil, nil, time.Time{}, nil}},
+	})
+
+	users, err := ScanAll[scanUser](context.Background(), Select(columns...).From("users").RunWith(db))
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+}
+
+func TestColumnsOfAs(t *testing.T) {
+	type Team struct {
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	userColumns, err := ColumnsOfAs[scanUser]("u", "user")
+	assert.NoError(t, err)
+	teamColumns, err := ColumnsOfAs[Team]("t", "team")
+	assert.NoError(t, err)
+	sql, _, err := Select(userColumns[:2]...).Column(teamColumns[0]).Column(teamColumns[1]).
+		From("users u").Join("teams t ON t.id = u.team_id").ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"SELECT u.id AS user_id, u.name AS user_name, t.id AS team_id, t.name AS team_name "+
+			"FROM users u JOIN teams t ON t.id = u.team_id", sql)
+
+	columns, err := ColumnsOfAs[Team]("", "team")
+	assert.NoError(t, err)
+	assert.Equal(t, []safeString{"id AS team_id", "name AS team_name"}, columns)
+
+	_, err = ColumnsOfAs[Team]("t", "team name")
+	assert.EqualError(t, err, `column prefix "team name" is not a plain identifier`)
+}
+
+func TestColumnsOfAsScan(t *testing.T) {
+	type Member struct {
+		UserID   int64  `db:"user_id"`
+		UserName string `db:"user_name"`
+		TeamID   int64  `db:"team_id"`
+		TeamName string `db:"team_name"`
+	}
+	type Team struct {
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	userColumns, err := ColumnsOfAs[Team]("u", "user")
+	assert.NoError(t, err)
+	teamColumns, err := ColumnsOfAs[Team]("t", "team")
+	assert.NoError(t, err)
+	db, _ := newFakeDB(fakeResult{
+		columns: []string{"user_id", "user_name", "team_id", "team_name"},
+		rows:    [][]driver.Value{{int64(1), "ann", int64(2), "ops"}},
+	})
+
+	q := Select(append(userColumns, teamColumns...)...).From("users u").Join("teams t ON t.id = u.team_id")
+	members, err := ScanAll[Member](context.Background(), q.RunWith(db))
+	assert.NoError(t, err)
+	assert.Equal(t, []Member{{UserID: 1, UserName: "ann", TeamID: 2, TeamName: "ops"}}, members)
+}
+
+func TestColumnsOfInvalid(t *testing.T) {
+	type Injected struct {
+		ID int64 `db:"id; DROP TABLE users"`
+	}
+	_, err := ColumnsOf[Injected]("")
+	assert.EqualError(t, err, `db tag "id; DROP TABLE users" of squirrel2.Injected is not a plain identifier`)
+
+	type Quoted struct {
+		ID int64 `db:"\"id\""`
+	}
+	_, err = ColumnsOf[Quoted]("")
+	assert.EqualError(t, err, `db tag "\"id\"" of squirrel2.Quoted is not a plain identifier`)
+
+	_, err = ColumnsOf[int]("")
+	assert.EqualError(t, err, "cannot derive columns from int; it isn't a struct")
+}
@@ -2,14 +2,19 @@ package squirrel2
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
 	"strconv"
+	"time"
 )
 
 type deleteData struct {
 	PlaceholderFormat PlaceholderFormat
 	RunWith           BaseRunner
+	Timeout           time.Duration
+	Tags              sqlTags
+	ExpectRollback    bool
 	Prefixes          []Sqlizer
 	From              safeString
 	WhereParts        []Sqlizer
@@ -23,10 +28,23 @@ func (d *deleteData) Exec() (sql.Result, error) {
 	if d.RunWith == nil {
 		return nil, ErrRunnerNotSet
 	}
+	if d.Timeout > 0 {
+		return d.ExecContext(context.Background())
+	}
 	return ExecWith(d.RunWith, d)
 }
 
 func (d *deleteData) ToSql() (sqlStr string, args []interface{}, err error) {
+	sqlStr, args, err = d.toSqlRaw()
+	if err != nil {
+		return
+	}
+
+	sqlStr, args, err = replacePlaceholders(d.PlaceholderFormat, sqlStr, args)
+	return
+}
+
+func (d *deleteData) toSqlRaw() (sqlStr string, args []interface{}, err error) {
 	if len(d.From) == 0 {
 		err = fmt.Errorf("delete statements must specify a From table")
 		return
@@ -82,7 +100,8 @@ func (d *deleteData) ToSql() (sqlStr string, args []interface{}, err error) {
 		}
 	}
 
-	sqlStr, err = d.PlaceholderFormat.ReplacePlaceholders(sql.String())
+	// The tags go with the statement, even when it is nested in another.
+	sqlStr = d.Tags.apply(sql.String())
 	return
 }
 
@@ -98,6 +117,8 @@ func DeleteBuilder(b statementBuilderType) deleteBuilder {
 		data: deleteData{
 			PlaceholderFormat: b.placeholderFormat,
 			RunWith:           b.runWith,
+			Timeout:           b.timeout,
+			Tags:              b.tags,
 			WhereParts:        b.whereParts,
 			Prefixes:          make([]Sqlizer, 0),
 			OrderBys:          make([]safeString, 0),
@@ -136,6 +157,39 @@ func (b deleteBuilder) RunWith(runner BaseRunner) deleteBuilder {
 	return b
 }
 
+// Timeout sets the time the statement may run for.
+//
+// See SelectBuilder.Timeout for more information.
+func (b deleteBuilder) Timeout(d time.Duration) deleteBuilder {
+	b.data.Timeout = d
+	return b
+}
+
+// Tag adds a tag to the comment of the statement.
+//
+// See SelectBuilder.Tag for more information.
+func (b deleteBuilder) Tag(key, value string) deleteBuilder {
+	b.data.Tags = b.data.Tags.with(key, value)
+	return b
+}
+
+// TagPosition sets where the comment holding the tags goes.
+//
+// See SelectBuilder.TagPosition for more information.
+func (b deleteBuilder) TagPosition(p TagPosition) deleteBuilder {
+	b.data.Tags.position = p
+	return b
+}
+
+// RollbackOnUnexpected makes ExecExpect and ExecExpectRange roll back the
+// transaction or savepoint they run with when their expectation fails.
+//
+// See UpdateBuilder.RollbackOnUnexpected for more information.
+func (b deleteBuilder) RollbackOnUnexpected() deleteBuilder {
+	b.data.ExpectRollback = true
+	return b
+}
+
 // Exec builds and Execs the query with the Runner set by RunWith.
 func (b deleteBuilder) Exec() (sql.Result, error) {
 	return b.data.Exec()
@@ -148,6 +202,10 @@ func (b deleteBuilder) ToSql() (string, []interface{}, error) {
 	return b.data.ToSql()
 }
 
+func (b deleteBuilder) toSqlRaw() (string, []interface{}, error) {
+	return b.data.toSqlRaw()
+}
+
 // MustSql builds the query into a SQL string and bound args.
 // It panics if there are any errors.
 func (b deleteBuilder) MustSql() (string, []interface{}) {
@@ -220,5 +278,8 @@ func (d *deleteData) Query() (*sql.Rows, error) {
 	if d.RunWith == nil {
 		return nil, ErrRunnerNotSet
 	}
+	if d.Timeout > 0 {
+		return d.QueryContext(context.Background())
+	}
 	return QueryWith(d.RunWith, d)
 }
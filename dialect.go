@@ -0,0 +1,207 @@
+package squirrel2
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Dialect is the interface that wraps the QuoteIdent method.
+//
+// QuoteIdent quotes a single identifier part, escaping any quote characters
+// embedded in it, so that it can be safely interpolated into a SQL statement.
+type Dialect interface {
+	QuoteIdent(part string) string
+}
+
+var (
+	// ANSI is a Dialect instance that quotes identifiers with double quotes
+	// (e.g. "users").
+	ANSI = ansiDialect{}
+
+	// Postgres is a Dialect instance for PostgreSQL.
+	Postgres = postgresDialect{}
+
+	// SQLite is a Dialect instance for SQLite.
+	SQLite = sqliteDialect{}
+
+	// MySQL is a Dialect instance for MySQL and MariaDB; it quotes identifiers
+	// with backticks (e.g. `users`).
+	MySQL = mysqlDialect{}
+
+	// SQLServer is a Dialect instance for Microsoft SQL Server; it quotes
+	// identifiers with square brackets (e.g. [users]).
+	SQLServer = sqlServerDialect{}
+)
+
+// literalDialect is implemented by the Dialects of this package to render
+// values as SQL literals. Dialects that don't implement it get ANSI literals.
+type literalDialect interface {
+	quoteString(s string) string
+	quoteBytes(b []byte) string
+	quoteBool(b bool) string
+	quoteTime(t time.Time) string
+}
+
+type ansiDialect struct{}
+
+func (ansiDialect) QuoteIdent(part string) string {
+	return quoteIdentWith(part, `"`, `"`)
+}
+
+func (ansiDialect) quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func (ansiDialect) quoteBytes(b []byte) string {
+	return "X'" + hex.EncodeToString(b) + "'"
+}
+
+func (ansiDialect) quoteBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+func (d ansiDialect) quoteTime(t time.Time) string {
+	return d.quoteString(t.Format("2006-01-02 15:04:05.999999999-07:00"))
+}
+
+type postgresDialect struct{ ansiDialect }
+
+func (postgresDialect) quoteBytes(b []byte) string {
+	return `'\x` + hex.EncodeToString(b) + "'::bytea"
+}
+
+type sqliteDialect struct{ ansiDialect }
+
+func (sqliteDialect) quoteBool(b bool) string {
+	return quoteBoolAsInt(b)
+}
+
+type mysqlDialect struct{ ansiDialect }
+
+func (mysqlDialect) QuoteIdent(part string) string {
+	return quoteIdentWith(part, "`", "`")
+}
+
+// quoteString also escapes backslashes, which MySQL treats as escape
+// characters unless the NO_BACKSLASH_ESCAPES mode is enabled.
+func (d mysqlDialect) quoteString(s string) string {
+	return d.ansiDialect.quoteString(strings.ReplaceAll(s, `\`, `\\`))
+}
+
+// quoteTime renders t in UTC, as DATETIME and TIMESTAMP literals can't have a
+// time zone offset.
+func (d mysqlDialect) quoteTime(t time.Time) string {
+	return d.quoteString(t.UTC().Format("2006-01-02 15:04:05.999999"))
+}
+
+type sqlServerDialect struct{ ansiDialect }
+
+func (sqlServerDialect) QuoteIdent(part string) string {
+	return quoteIdentWith(part, "[", "]")
+}
+
+// quoteString prefixes the literal with N so that it's read as Unicode.
+func (d sqlServerDialect) quoteString(s string) string {
+	return "N" + d.ansiDialect.quoteString(s)
+}
+
+func (sqlServerDialect) quoteBytes(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
+
+func (sqlServerDialect) quoteBool(b bool) string {
+	return quoteBoolAsInt(b)
+}
+
+func (d sqlServerDialect) quoteTime(t time.Time) string {
+	return d.ansiDialect.quoteString(t.Format("2006-01-02T15:04:05.9999999-07:00"))
+}
+
+func quoteBoolAsInt(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// quoteIdentWith wraps part in open and close, doubling every occurrence of
+// close inside of it.
+func quoteIdentWith(part, open, close string) string {
+	return open + strings.ReplaceAll(part, close, close+close) + close
+}
+
+// maxIdentLength is the longest identifier part accepted by QuoteIdent; it matches
+// the limit of SQL Server, the most generous of the supported dialects.
+const maxIdentLength = 128
+
+// validateIdentPart returns an error if part can't be used as an identifier,
+// quoted or not.
+func validateIdentPart(part string) error {
+	if part == "" {
+		return fmt.Errorf("identifiers must not be empty")
+	}
+	if len(part) > maxIdentLength {
+		return fmt.Errorf("identifier %q is longer than %d bytes", part, maxIdentLength)
+	}
+	if !utf8.ValidString(part) {
+		return fmt.Errorf("identifier %q is not valid UTF-8", part)
+	}
+	for _, r := range part {
+		if r < 0x20 || r == 0x7f {
+			return fmt.Errorf("identifier %q contains control characters", part)
+		}
+	}
+	return nil
+}
+
+// QuoteIdent validates parts and joins them with dots into a (possibly
+// schema-qualified) identifier quoted according to d. Unlike a dynamic string
+// cast to a safeString, the result is safe to use for table and column names
+// chosen at runtime, and it's accepted by every builder method that takes one.
+// Use the Dialect of the database the statement runs on: e.g. MySQL reads the
+// ANSI "users" as a string unless ANSI_QUOTES is enabled.
+//
+// Ex:
+//
+//	id, err := QuoteIdent(MySQL, "app", "users") // `app`.`users`
+//
+//	column, err := QuoteIdent(Postgres, userColumn)
+//	if err != nil {
+//		return err
+//	}
+//	q := Select(column).From("users")
+func QuoteIdent(d Dialect, parts ...string) (safeString, error) {
+	if len(parts) == 0 {
+		return "", fmt.Errorf("identifiers must have at least one part")
+	}
+	quoted := make([]string, len(parts))
+	for i, part := range parts {
+		if err := validateIdentPart(part); err != nil {
+			return "", err
+		}
+		quoted[i] = d.QuoteIdent(part)
+	}
+	//squirrelvet:allow the parts are validated and quoted
+	return safeString(strings.Join(quoted, ".")), nil
+}
+
+// Ident is like QuoteIdent, but panics if parts aren't a valid identifier. It
+// suits names that are known to be valid, e.g. chosen from an allowlist; use
+// QuoteIdent for names given by users.
+//
+// Ex:
+//
+//	Select(Ident(Postgres, column)).From(Ident(Postgres, "public", "users"))
+func Ident(d Dialect, parts ...string) safeString {
+	ident, err := QuoteIdent(d, parts...)
+	if err != nil {
+		panic(err)
+	}
+	return ident
+}
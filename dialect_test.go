@@ -0,0 +1,75 @@
+package squirrel2
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuoteIdent(t *testing.T) {
+	tests := []struct {
+		dialect  Dialect
+		parts    []string
+		expected string
+	}{
+		{ANSI, []string{"users"}, `"users"`},
+		{Postgres, []string{"public", "users"}, `"public"."users"`},
+		{SQLite, []string{`we"ird`}, `"we""ird"`},
+		{MySQL, []string{"app", "users"}, "`app`.`users`"},
+		{MySQL, []string{"we`ird"}, "`we``ird`"},
+		{SQLServer, []string{"dbo", "users"}, "[dbo].[users]"},
+		{SQLServer, []string{"we]ird"}, "[we]]ird]"},
+	}
+	for _, test := range tests {
+		ident, err := QuoteIdent(test.dialect, test.parts...)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, string(ident))
+	}
+}
+
+func TestQuoteIdentErrors(t *testing.T) {
+	_, err := QuoteIdent(ANSI)
+	assert.Error(t, err)
+
+	_, err = QuoteIdent(ANSI, "public", "")
+	assert.Error(t, err)
+
+	_, err = QuoteIdent(ANSI, "bad\x00name")
+	assert.Error(t, err)
+
+	_, err = QuoteIdent(ANSI, "\xff")
+	assert.Error(t, err)
+
+	_, err = QuoteIdent(ANSI, strings.Repeat("x", maxIdentLength+1))
+	assert.Error(t, err)
+}
+
+func TestIdent(t *testing.T) {
+	assert.Equal(t, safeString("`app`.`users`"), Ident(MySQL, "app", "users"))
+	assert.Panics(t, func() { Ident(ANSI, "public", "") })
+}
+
+func TestIdentInBuilders(t *testing.T) {
+	column := "name"
+	injected := `x" FROM secrets --`
+
+	sql, _, err := Select(Ident(ANSI, column), Ident(ANSI, injected)).
+		From(Ident(ANSI, "public", "users")).
+		OrderBy(Ident(ANSI, column)).
+		ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT "name", "x"" FROM secrets --" FROM "public"."users" ORDER BY "name"`, sql)
+
+	sql, _, err = Insert(Ident(MySQL, "users")).Columns(Ident(MySQL, column)).Values("foo").ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO `users` (`name`) VALUES (?)", sql)
+
+	sql, _, err = Update(Ident(SQLServer, "users")).Set(Ident(SQLServer, column), "foo").ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, `UPDATE [users] SET [name] = ?`, sql)
+
+	sql, _, err = Delete(Ident(Postgres, "users")).Where(Eq{Ident(Postgres, column): "foo"}).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, `DELETE FROM "users" WHERE "name" = ?`, sql)
+}
@@ -0,0 +1,82 @@
+package squirrel2
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrNoRowsAffected matches, with errors.Is, the RowsAffectedErrors of the
+// statements that affected no rows.
+var ErrNoRowsAffected = errors.New("no rows affected")
+
+// ErrUnexpectedRowsAffected matches, with errors.Is, every RowsAffectedError.
+var ErrUnexpectedRowsAffected = errors.New("unexpected number of rows affected")
+
+// RowsAffectedError is returned by ExecExpect and ExecExpectRange when a
+// statement affects an unexpected number of rows, e.g. because the row to
+// update is missing or was updated concurrently.
+type RowsAffectedError struct {
+	// Min and Max are the expected bounds of RowsAffected. Max is negative
+	// if there is no upper bound.
+	Min, Max int64
+	// RowsAffected is the number of rows the statement affected.
+	RowsAffected int64
+}
+
+func (e *RowsAffectedError) Error() string {
+	var expected string
+	switch {
+	case e.Min == e.Max:
+		expected = fmt.Sprintf("%d", e.Min)
+	case e.Max < 0:
+		expected = fmt.Sprintf("at least %d", e.Min)
+	default:
+		expected = fmt.Sprintf("%d to %d", e.Min, e.Max)
+	}
+	return fmt.Sprintf("%d rows affected, expected %s", e.RowsAffected, expected)
+}
+
+// Is reports whether target is ErrUnexpectedRowsAffected, or
+// ErrNoRowsAffected if no rows were affected.
+func (e *RowsAffectedError) Is(target error) bool {
+	return target == ErrUnexpectedRowsAffected || target == ErrNoRowsAffected && e.RowsAffected == 0
+}
+
+// execExpect runs exec and checks that it affected min to max rows. If it
+// didn't and rollback is set, it rolls runner back if it's a transaction.
+// Invalid bounds are reported without running exec.
+func execExpect(ctx context.Context, exec func(ctx context.Context) (sql.Result, error), runner BaseRunner, min, max int64, rollback bool) (sql.Result, error) {
+	if min < 0 || max >= 0 && min > max {
+		return nil, fmt.Errorf("invalid expected rows affected: min %d, max %d", min, max)
+	}
+	res, err := exec(ctx)
+	if err != nil {
+		return res, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return res, err
+	}
+	if n >= min && (max < 0 || n <= max) {
+		return res, nil
+	}
+
+	err = &RowsAffectedError{Min: min, Max: max, RowsAffected: n}
+	if !rollback {
+		return res, err
+	}
+	switch r := runner.(type) {
+	case *stdsqlCtxRunner:
+		runner = r.StdSqlCtx
+	case *stdsqlRunner:
+		runner = r.StdSql
+	}
+	if tx, ok := runner.(interface{ Rollback() error }); ok {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return res, fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+	}
+	return res, err
+}
@@ -0,0 +1,108 @@
+package squirrel2
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecExpect(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	ctx := context.Background()
+
+	res, err := Update("users").Set("name", "ann").Where(Eq{"id": 1}).RunWith(db).ExecExpect(ctx, 1)
+	assert.NoError(t, err)
+	n, _ := res.RowsAffected()
+	assert.Equal(t, int64(1), n)
+
+	fake.rowsAffected = func(string) int64 { return 0 }
+	_, err = Delete("users").Where(Eq{"id": 1}).RunWith(db).ExecExpect(ctx, 1)
+	assert.ErrorIs(t, err, ErrNoRowsAffected)
+	assert.ErrorIs(t, err, ErrUnexpectedRowsAffected)
+	assert.EqualError(t, err, "0 rows affected, expected 1")
+
+	fake.rowsAffected = func(string) int64 { return 3 }
+	_, err = Insert("users").Values(1).RunWith(db).ExecExpect(ctx, 2)
+	assert.NotErrorIs(t, err, ErrNoRowsAffected)
+	var rowsErr *RowsAffectedError
+	if assert.ErrorAs(t, err, &rowsErr) {
+		assert.Equal(t, RowsAffectedError{Min: 2, Max: 2, RowsAffected: 3}, *rowsErr)
+	}
+
+	// Errors of the statement are returned as is.
+	boom := errors.New("boom")
+	fake.execErr = func(string) error { return boom }
+	_, err = Update("users").Set("x", 1).RunWith(db).ExecExpect(ctx, 1)
+	assert.Equal(t, boom, err)
+}
+
+func TestExecExpectRange(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	ctx := context.Background()
+	b := Delete("sessions").RunWith(db)
+
+	fake.rowsAffected = func(string) int64 { return 3 }
+	_, err := b.ExecExpectRange(ctx, 1, 3)
+	assert.NoError(t, err)
+	_, err = b.ExecExpectRange(ctx, 1, -1)
+	assert.NoError(t, err)
+	_, err = b.ExecExpectRange(ctx, 0, 2)
+	assert.EqualError(t, err, "3 rows affected, expected 0 to 2")
+	_, err = b.ExecExpectRange(ctx, 4, -1)
+	assert.EqualError(t, err, "3 rows affected, expected at least 4")
+
+	// Invalid bounds fail before the statement runs.
+	before := len(fake.statements())
+	_, err = b.ExecExpectRange(ctx, 3, 1)
+	assert.EqualError(t, err, "invalid expected rows affected: min 3, max 1")
+	_, err = b.ExecExpect(ctx, -1)
+	assert.EqualError(t, err, "invalid expected rows affected: min -1, max -1")
+	assert.Len(t, fake.statements(), before)
+}
+
+func TestExecExpectRollback(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	fake.rowsAffected = func(query string) int64 {
+		if strings.HasPrefix(query, "UPDATE") {
+			return 0
+		}
+		return 1
+	}
+	ctx := context.Background()
+
+	// The savepoint is rolled back, the outer transaction goes on.
+	err := WithTx(ctx, db, nil, func(tx RunnerContext) error {
+		_, err := Insert("t").Values(1).RunWith(tx).ExecExpect(ctx, 1)
+		assert.NoError(t, err)
+		err = tx.(*Tx).WithTx(ctx, func(tx RunnerContext) error {
+			_, err := Update("t").Set("x", 1).RunWith(tx).RollbackOnUnexpected().ExecExpect(ctx, 1)
+			return err
+		})
+		assert.ErrorIs(t, err, ErrNoRowsAffected)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"BEGIN",
+		"INSERT INTO t VALUES (?)",
+		"SAVEPOINT sp_1",
+		"UPDATE t SET x = ?",
+		"ROLLBACK TO SAVEPOINT sp_1",
+		"RELEASE SAVEPOINT sp_1",
+		"COMMIT",
+	}, fake.statements())
+
+	fake.log = nil
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+	_, err = Update("t").Set("x", 1).RunWith(tx).RollbackOnUnexpected().ExecExpect(ctx, 1)
+	assert.ErrorIs(t, err, ErrNoRowsAffected)
+	assert.Equal(t, []string{"BEGIN", "UPDATE t SET x = ?", "ROLLBACK"}, fake.statements())
+
+	// Runners that aren't transactions have nothing to roll back.
+	_, err = Update("t").Set("x", 1).RunWith(db).RollbackOnUnexpected().ExecExpect(ctx, 1)
+	assert.ErrorIs(t, err, ErrNoRowsAffected)
+}
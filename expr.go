@@ -25,11 +25,31 @@ type expr struct {
 // Ex:
 //
 //	Expr("FROM_UNIXTIME(?)", t)
+//
+// Instead of positional ? placeholders, the fragment may use :name or @name
+// markers when args is a single map[string]interface{} or only sql.NamedArgs.
+// A name can be used any number of times, and is bound to a single
+// placeholder by the PlaceholderFormats that support it:
+//
+//	Expr("created_at > :since OR updated_at > :since", map[string]interface{}{"since": t})
+//	Expr("owner = @id", sql.Named("id", 42))
+//
+// Fragments with "?" placeholders or without markers bind their args
+// positionally, so maps (e.g. JSON values) and sql.NamedArgs can still be
+// passed as values.
 func Expr(sql safeString, args ...interface{}) Sqlizer {
 	return expr{sql: sql, args: args}
 }
 
 func (e expr) ToSql() (sql string, args []interface{}, err error) {
+	return resolveNamedArgs(e.toSqlRaw())
+}
+
+func (e expr) toSqlRaw() (sql string, args []interface{}, err error) {
+	if bindings, ok := namedBindings(string(e.sql), e.args); ok {
+		return bindNamedToSql(string(e.sql), bindings)
+	}
+
 	simple := true
 	for _, arg := range e.args {
 		if _, ok := arg.(Sqlizer); ok {
@@ -42,51 +62,42 @@ func (e expr) ToSql() (sql string, args []interface{}, err error) {
 
 	buf := &bytes.Buffer{}
 	ap := e.args
-	sp := string(e.sql)
-
-	var isql string
-	var iargs []interface{}
-
-	for err == nil && len(ap) > 0 && len(sp) > 0 {
-		i := strings.Index(sp, "?")
-		if i < 0 {
-			// no more placeholders
-			break
-		}
-		if len(sp) > i+1 && sp[i+1:i+2] == "?" {
-			// escaped "??"; append it and step past
-			buf.WriteString(sp[:i+2])
-			sp = sp[i+2:]
-			continue
+	err = sqlLexer{}.scan(string(e.sql), func(tok sqlToken) error {
+		if tok.kind != sqlPlaceholder || len(ap) == 0 {
+			buf.WriteString(tok.text)
+			return nil
 		}
 
 		if as, ok := ap[0].(Sqlizer); ok {
 			// sqlizer argument; expand it and append the result
-			isql, iargs, err = as.ToSql()
-			buf.WriteString(sp[:i])
+			isql, iargs, err := nestedToSql(as)
+			if err != nil {
+				return err
+			}
 			buf.WriteString(isql)
 			args = append(args, iargs...)
 		} else {
 			// normal argument; append it and the placeholder
-			buf.WriteString(sp[:i+1])
+			buf.WriteString(tok.text)
 			args = append(args, ap[0])
 		}
-
-		// step past the argument and placeholder
 		ap = ap[1:]
-		sp = sp[i+1:]
-	}
+		return nil
+	})
 
-	// append the remaining sql and arguments
-	buf.WriteString(sp)
+	// append the remaining arguments
 	return buf.String(), append(args, ap...), err
 }
 
 type concatExpr []Sqlizer
 
 func (ce concatExpr) ToSql() (sql string, args []interface{}, err error) {
+	return resolveNamedArgs(ce.toSqlRaw())
+}
+
+func (ce concatExpr) toSqlRaw() (sql string, args []interface{}, err error) {
 	for _, part := range ce {
-		pSql, pArgs, err := part.ToSql()
+		pSql, pArgs, err := nestedToSql(part)
 		if err != nil {
 			return "", nil, err
 		}
@@ -122,7 +133,11 @@ func Alias(expr Sqlizer, alias safeString) aliasExpr {
 }
 
 func (e aliasExpr) ToSql() (sql string, args []interface{}, err error) {
-	sql, args, err = e.expr.ToSql()
+	return resolveNamedArgs(e.toSqlRaw())
+}
+
+func (e aliasExpr) toSqlRaw() (sql string, args []interface{}, err error) {
+	sql, args, err = nestedToSql(e.expr)
 	if err == nil {
 		sql = fmt.Sprintf("(%s) AS %s", sql, e.alias)
 	}
@@ -396,6 +411,10 @@ func (c conj) join(sep, defaultExpr string) (sql string, args []interface{}, err
 type And conj
 
 func (a And) ToSql() (string, []interface{}, error) {
+	return resolveNamedArgs(a.toSqlRaw())
+}
+
+func (a And) toSqlRaw() (string, []interface{}, error) {
 	return conj(a).join(" AND ", sqlTrue)
 }
 
@@ -403,6 +422,10 @@ func (a And) ToSql() (string, []interface{}, error) {
 type Or conj
 
 func (o Or) ToSql() (string, []interface{}, error) {
+	return resolveNamedArgs(o.toSqlRaw())
+}
+
+func (o Or) toSqlRaw() (string, []interface{}, error) {
 	return conj(o).join(" OR ", sqlFalse)
 }
 
@@ -16,8 +16,12 @@ func ExprIf(expression Sqlizer, include bool) Sqlizer {
 }
 
 func (eIf exprIf) ToSql() (sql string, args []interface{}, err error) {
+	return resolveNamedArgs(eIf.toSqlRaw())
+}
+
+func (eIf exprIf) toSqlRaw() (sql string, args []interface{}, err error) {
 	if eIf.include {
-		return eIf.expression.ToSql()
+		return nestedToSql(eIf.expression)
 	}
 	return "", nil, nil
 }
@@ -0,0 +1,172 @@
+package squirrel2
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+)
+
+// fakeResult is the canned result of a fakeDB query.
+type fakeResult struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+// fakeDB is a database/sql driver answering every query with result. It logs
+// the statements it runs, including transaction control, and can be told to
+// fail some of them.
+type fakeDB struct {
+	mu       sync.Mutex
+	result   fakeResult
+	lastSql  string
+	lastArgs []driver.NamedValue
+	openRows int
+	log      []string
+	// execErr, if set, returns the error of each query, Exec or Commit; the
+	// latter is passed "COMMIT" as its query.
+	execErr func(query string) error
+	// prepareHook, if set, is called before each statement is prepared.
+	prepareHook func(query string)
+	// rowsAffected, if set, returns the rows affected by each Exec, 1
+	// otherwise.
+	rowsAffected func(query string) int64
+}
+
+func newFakeDB(result fakeResult) (*sql.DB, *fakeDB) {
+	f := &fakeDB{result: result}
+	return sql.OpenDB(f), f
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+// run logs query, returning the error it should fail with.
+func (f *fakeDB) run(query string, args []driver.NamedValue) error {
+	f.mu.Lock()
+	f.lastSql, f.lastArgs = query, args
+	f.log = append(f.log, query)
+	execErr := f.execErr
+	f.mu.Unlock()
+	if execErr != nil {
+		return execErr(query)
+	}
+	return nil
+}
+
+func (f *fakeDB) statements() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.log...)
+}
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Close() error { return nil }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	if c.db.prepareHook != nil {
+		c.db.prepareHook(query)
+	}
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.log = append(c.db.log, "PREPARE "+query)
+	return fakeStmt{conn: c, query: query}, nil
+}
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.log = append(c.db.log, "BEGIN")
+	return fakeTx{c.db}, nil
+}
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.db.run(query, args); err != nil {
+		return nil, err
+	}
+	if c.db.rowsAffected != nil {
+		return driver.RowsAffected(c.db.rowsAffected(query)), nil
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.db.run(query, args); err != nil {
+		return nil, err
+	}
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.openRows++
+	return &fakeRows{db: c.db, result: c.db.result}, nil
+}
+
+type fakeStmt struct {
+	conn  fakeConn
+	query string
+}
+
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Close() error {
+	s.conn.db.mu.Lock()
+	defer s.conn.db.mu.Unlock()
+	s.conn.db.log = append(s.conn.db.log, "CLOSE "+s.query)
+	return nil
+}
+
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s fakeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+type fakeTx struct{ db *fakeDB }
+
+func (tx fakeTx) Commit() error { return tx.db.run("COMMIT", nil) }
+
+func (tx fakeTx) Rollback() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.log = append(tx.db.log, "ROLLBACK")
+	return nil
+}
+
+type fakeRows struct {
+	db     *fakeDB
+	result fakeResult
+	next   int
+}
+
+func (r *fakeRows) Columns() []string { return r.result.columns }
+
+func (r *fakeRows) Close() error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	r.db.openRows--
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,504 @@
+package squirrel2
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FilterType is the type the values compared to a filter field are coerced to.
+type FilterType int
+
+const (
+	// FilterString accepts string values.
+	FilterString FilterType = iota
+	// FilterInt accepts integers, as numbers or strings, coerced to int64.
+	FilterInt
+	// FilterFloat accepts numbers, or strings holding one, coerced to float64.
+	FilterFloat
+	// FilterBool accepts true and false, or strings holding them, coerced to
+	// bool.
+	FilterBool
+	// FilterTime accepts strings holding RFC 3339 timestamps, e.g.
+	// '2024-05-01T10:00:00Z', or dates, e.g. '2024-05-01', coerced to
+	// time.Time.
+	FilterTime
+)
+
+func (t FilterType) String() string {
+	switch t {
+	case FilterString:
+		return "string"
+	case FilterInt:
+		return "integer"
+	case FilterFloat:
+		return "number"
+	case FilterBool:
+		return "boolean"
+	case FilterTime:
+		return "time"
+	}
+	return fmt.Sprintf("FilterType(%d)", int(t))
+}
+
+// FilterField is a field that filters can compare.
+type FilterField struct {
+	// Column is the SQL of the field.
+	Column safeString
+	// Type is the type the values compared to the field are coerced to.
+	Type FilterType
+}
+
+// FilterFields maps the public names of the fields that filters can compare
+// to their FilterField. It's the allowlist of a FilterSpec.
+type FilterFields map[string]FilterField
+
+// FilterError is returned by FilterSpec.Parse and FilterSpec.DecodeJSON for
+// filters they can't accept, and by FilterSpec.EncodeJSON for filters it can't
+// encode.
+type FilterError struct {
+	// Pos is the byte offset in the filter of the token the error is about,
+	// or -1 for errors of EncodeJSON.
+	Pos int
+	// Path is the location in JSON documents of the value the error is
+	// about, e.g. "$or[1].age.$gte", or "" for filters parsed by Parse and
+	// errors about the whole document.
+	Path string
+	// Msg describes the error.
+	Msg string
+}
+
+func (e *FilterError) Error() string {
+	if e.Path != "" {
+		return fmt.Sprintf("%s at %s", e.Msg, e.Path)
+	}
+	if e.Pos < 0 {
+		return e.Msg
+	}
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+// Default limits of a FilterSpec.
+const (
+	DefaultFilterMaxLength = 4096
+	DefaultFilterMaxDepth  = 8
+	DefaultFilterMaxTerms  = 32
+)
+
+// FilterSpec parses filters given by users, e.g. in "?filter=...", into
+// Sqlizers, accepting only the fields of its allowlist. It also decodes and
+// encodes filters as JSON documents; see DecodeJSON.
+//
+// Filters compare fields to values, e.g. "status eq 'active'", and combine
+// comparisons with and, or, not and parentheses, e.g.
+//
+//	status eq 'active' and (age gt 30 or vip eq true)
+//
+// The operators are eq, ne, gt, ge, lt, le, like and in, e.g. "id in (1, 2)".
+// Values are strings in single quotes, where a quote is escaped by doubling
+// it, numbers, true, false and null, e.g. "deleted_at eq null". Keywords are
+// case-insensitive.
+//
+// Ex:
+//
+//	var usersFilter = NewFilterSpec(FilterFields{
+//		"status": {Column: "users.status"},
+//		"age":    {Column: "users.age", Type: FilterInt},
+//	})
+//
+//	where, err := usersFilter.Parse(r.URL.Query().Get("filter"))
+//	if err != nil {
+//		return err // e.g. unknown filter field "password" at position 0
+//	}
+//	q := Select("*").From("users").Where(where)
+type FilterSpec struct {
+	fields    FilterFields
+	maxLength int
+	maxDepth  int
+	maxTerms  int
+}
+
+// NewFilterSpec returns a FilterSpec accepting the fields of fields, with the
+// default limits.
+func NewFilterSpec(fields FilterFields) FilterSpec {
+	return FilterSpec{
+		fields:    fields,
+		maxLength: DefaultFilterMaxLength,
+		maxDepth:  DefaultFilterMaxDepth,
+		maxTerms:  DefaultFilterMaxTerms,
+	}
+}
+
+// MaxLength sets the maximum length of filters, in bytes.
+func (s FilterSpec) MaxLength(n int) FilterSpec {
+	s.maxLength = n
+	return s
+}
+
+// MaxDepth sets the maximum nesting of parentheses and nots in filters.
+func (s FilterSpec) MaxDepth(n int) FilterSpec {
+	s.maxDepth = n
+	return s
+}
+
+// MaxTerms sets the maximum number of comparisons in filters, counting each
+// value of an in as one.
+func (s FilterSpec) MaxTerms(n int) FilterSpec {
+	s.maxTerms = n
+	return s
+}
+
+// Parse parses filter into a Sqlizer made of And, Or, Eq, NotEq, Gt, GtOrEq,
+// Lt, LtOrEq and Like. An empty filter matches every row.
+//
+// Parse returns a *FilterError for filters that are invalid, use fields that
+// aren't allowed, have values that can't be coerced to the type of their
+// field, or exceed the limits of s.
+func (s FilterSpec) Parse(filter string) (Sqlizer, error) {
+	if len(filter) > s.maxLength {
+		return nil, &FilterError{Pos: s.maxLength, Msg: fmt.Sprintf("filter longer than %d bytes", s.maxLength)}
+	}
+	tokens, err := scanFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{spec: s, tokens: tokens}
+	if p.peek().kind == filterEOF {
+		return And{}, nil
+	}
+	pred, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != filterEOF {
+		return nil, tok.errorf("unexpected %s", tok)
+	}
+	return pred, nil
+}
+
+type filterTokenKind int
+
+const (
+	filterEOF filterTokenKind = iota
+	filterIdent
+	filterString
+	filterNumber
+	filterLParen
+	filterRParen
+	filterComma
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	// text is the text of the token, unquoted for strings.
+	text string
+	pos  int
+}
+
+func (t filterToken) String() string {
+	switch t.kind {
+	case filterEOF:
+		return "end of filter"
+	case filterString:
+		return fmt.Sprintf("string %q", t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func (t filterToken) errorf(format string, args ...interface{}) *FilterError {
+	return &FilterError{Pos: t.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// is reports whether t is the keyword kw.
+func (t filterToken) is(kw string) bool {
+	return t.kind == filterIdent && strings.EqualFold(t.text, kw)
+}
+
+func scanFilter(filter string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(filter); {
+		c := filter[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, filterToken{kind: filterLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, filterToken{kind: filterRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, filterToken{kind: filterComma, text: ",", pos: i})
+			i++
+		case c == '\'':
+			var sb strings.Builder
+			j := i + 1
+			for ; ; j++ {
+				if j >= len(filter) {
+					return nil, &FilterError{Pos: i, Msg: "unterminated string"}
+				}
+				if filter[j] == '\'' {
+					if byteAt(filter, j+1) != '\'' {
+						break
+					}
+					j++
+				}
+				sb.WriteByte(filter[j])
+			}
+			tokens = append(tokens, filterToken{kind: filterString, text: sb.String(), pos: i})
+			i = j + 1
+		case c == '-' || c == '.' || c >= '0' && c <= '9':
+			j := i + 1
+			for j < len(filter) && (isIdentByte(filter[j]) || filter[j] == '.' ||
+				(filter[j] == '+' || filter[j] == '-') && (filter[j-1] == 'e' || filter[j-1] == 'E')) {
+				j++
+			}
+			tokens = append(tokens, filterToken{kind: filterNumber, text: filter[i:j], pos: i})
+			i = j
+		case isIdentByte(c):
+			j := i + 1
+			for j < len(filter) && (isIdentByte(filter[j]) || filter[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, filterToken{kind: filterIdent, text: filter[i:j], pos: i})
+			i = j
+		default:
+			return nil, &FilterError{Pos: i, Msg: fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+	return append(tokens, filterToken{kind: filterEOF, pos: len(filter)}), nil
+}
+
+type filterParser struct {
+	spec   FilterSpec
+	tokens []filterToken
+	terms  int
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[0]
+}
+
+func (p *filterParser) next() filterToken {
+	tok := p.tokens[0]
+	if tok.kind != filterEOF {
+		p.tokens = p.tokens[1:]
+	}
+	return tok
+}
+
+func (p *filterParser) expect(kind filterTokenKind, what string) (filterToken, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, tok.errorf("expected %s, found %s", what, tok)
+	}
+	return tok, nil
+}
+
+func (p *filterParser) parseOr(depth int) (Sqlizer, error) {
+	pred, err := p.parseAnd(depth)
+	if err != nil || !p.peek().is("or") {
+		return pred, err
+	}
+	or := Or{pred}
+	for p.peek().is("or") {
+		p.next()
+		if pred, err = p.parseAnd(depth); err != nil {
+			return nil, err
+		}
+		or = append(or, pred)
+	}
+	return or, nil
+}
+
+func (p *filterParser) parseAnd(depth int) (Sqlizer, error) {
+	pred, err := p.parseUnary(depth)
+	if err != nil || !p.peek().is("and") {
+		return pred, err
+	}
+	and := And{pred}
+	for p.peek().is("and") {
+		p.next()
+		if pred, err = p.parseUnary(depth); err != nil {
+			return nil, err
+		}
+		and = append(and, pred)
+	}
+	return and, nil
+}
+
+func (p *filterParser) parseUnary(depth int) (Sqlizer, error) {
+	tok := p.peek()
+	if !tok.is("not") && tok.kind != filterLParen {
+		return p.parseComparison()
+	}
+	if depth >= p.spec.maxDepth {
+		return nil, tok.errorf("filter nested deeper than %d", p.spec.maxDepth)
+	}
+	p.next()
+	if tok.is("not") {
+		pred, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return Expr("NOT (?)", pred), nil
+	}
+	pred, err := p.parseOr(depth + 1)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(filterRParen, `")"`); err != nil {
+		return nil, err
+	}
+	return pred, nil
+}
+
+func (p *filterParser) parseComparison() (Sqlizer, error) {
+	fieldTok, err := p.expect(filterIdent, "field")
+	if err != nil {
+		return nil, err
+	}
+	field, ok := p.spec.fields[fieldTok.text]
+	if !ok {
+		return nil, fieldTok.errorf("unknown filter field %q", fieldTok.text)
+	}
+	opTok, err := p.expect(filterIdent, "operator")
+	if err != nil {
+		return nil, err
+	}
+	op := strings.ToLower(opTok.text)
+	col := field.Column
+
+	if op == "in" {
+		if _, err := p.expect(filterLParen, `"("`); err != nil {
+			return nil, err
+		}
+		var values []interface{}
+		for {
+			value, err := p.parseValue(field)
+			if err != nil {
+				return nil, err
+			}
+			if value == nil {
+				return nil, opTok.errorf("null can't be in a list")
+			}
+			values = append(values, value)
+			if p.peek().kind != filterComma {
+				break
+			}
+			p.next()
+		}
+		if _, err := p.expect(filterRParen, `"," or ")"`); err != nil {
+			return nil, err
+		}
+		return Eq{col: values}, nil
+	}
+
+	switch op {
+	case "eq", "ne", "gt", "ge", "lt", "le", "like":
+	default:
+		return nil, opTok.errorf("unknown operator %q", opTok.text)
+	}
+	if op == "like" && field.Type != FilterString {
+		return nil, opTok.errorf("like needs a string field, %q is %s", fieldTok.text, field.Type)
+	}
+	value, err := p.parseValue(field)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil && op != "eq" && op != "ne" {
+		return nil, opTok.errorf("%s can't compare to null", op)
+	}
+
+	switch op {
+	case "eq":
+		return Eq{col: value}, nil
+	case "ne":
+		return NotEq{col: value}, nil
+	case "gt":
+		return Gt{col: value}, nil
+	case "ge":
+		return GtOrEq{col: value}, nil
+	case "lt":
+		return Lt{col: value}, nil
+	case "le":
+		return LtOrEq{col: value}, nil
+	default:
+		return Like{col: value}, nil
+	}
+}
+
+// parseValue parses a value and coerces it to the type of field. A null value
+// is returned as nil.
+func (p *filterParser) parseValue(field FilterField) (interface{}, error) {
+	tok := p.next()
+	p.terms++
+	if p.terms > p.spec.maxTerms {
+		return nil, tok.errorf("filter has more than %d terms", p.spec.maxTerms)
+	}
+
+	var kind filterValueKind
+	switch {
+	case tok.is("null"):
+		return nil, nil
+	case tok.kind == filterString:
+		kind = filterStringValue
+	case tok.kind == filterNumber:
+		kind = filterNumberValue
+	case tok.is("true") || tok.is("false"):
+		kind, tok.text = filterBoolValue, strings.ToLower(tok.text)
+	default:
+		return nil, tok.errorf("expected value, found %s", tok)
+	}
+	value, ok := coerceFilterValue(field.Type, kind, tok.text)
+	if !ok {
+		return nil, tok.errorf("invalid %s value %s", field.Type, tok)
+	}
+	return value, nil
+}
+
+// filterValueKind is the kind of a value as written in a filter.
+type filterValueKind int
+
+const (
+	filterStringValue filterValueKind = iota
+	filterNumberValue
+	filterBoolValue
+)
+
+// coerceFilterValue coerces the value written as text to typ, reporting
+// whether it could. Bools are written "true" or "false".
+func coerceFilterValue(typ FilterType, kind filterValueKind, text string) (interface{}, bool) {
+	switch typ {
+	case FilterString:
+		return text, kind == filterStringValue
+	case FilterInt:
+		if kind == filterBoolValue {
+			return nil, false
+		}
+		n, err := strconv.ParseInt(text, 10, 64)
+		return n, err == nil
+	case FilterFloat:
+		if kind == filterBoolValue {
+			return nil, false
+		}
+		f, err := strconv.ParseFloat(text, 64)
+		return f, err == nil
+	case FilterBool:
+		if kind == filterNumberValue || text != "true" && text != "false" {
+			return nil, false
+		}
+		return text == "true", true
+	case FilterTime:
+		if kind != filterStringValue {
+			return nil, false
+		}
+		if t, err := time.Parse(time.RFC3339Nano, text); err == nil {
+			return t, true
+		}
+		t, err := time.Parse(time.DateOnly, text)
+		return t, err == nil
+	}
+	return nil, false
+}
@@ -0,0 +1,420 @@
+package squirrel2
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// DecodeJSON decodes a JSON filter document into a Sqlizer made of And, Or,
+// Eq, NotEq, Gt, GtOrEq, Lt, LtOrEq and Like.
+//
+// Documents are objects mapping fields to values, e.g. {"status": "active"},
+// or to objects of operators, e.g. {"age": {"$gte": 18, "$lt": 65}}. The
+// operators are $eq, $ne, $gt, $gte, $lt, $lte, $like, $in and $nin, which
+// take arrays, and $null, which takes true for IS NULL or false for IS NOT
+// NULL. The $and and $or keys take arrays of documents, e.g.
+//
+//	{"status": "active", "$or": [{"age": {"$gt": 30}}, {"vip": true}]}
+//
+// The fields and operators of an object are ANDed, in the order of their keys.
+// An empty document matches every row.
+//
+// DecodeJSON returns a *FilterError for documents that are invalid, use fields
+// that aren't allowed, have values that can't be coerced to the type of their
+// field, or exceed the limits of s, nesting $and and $or counting as depth.
+func (s FilterSpec) DecodeJSON(data []byte) (Sqlizer, error) {
+	if len(data) > s.maxLength {
+		return nil, &FilterError{Pos: s.maxLength, Msg: fmt.Sprintf("filter longer than %d bytes", s.maxLength)}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		pos := len(data)
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) && syntaxErr.Offset > 0 {
+			// Offset is just after the invalid byte.
+			pos = int(syntaxErr.Offset) - 1
+		}
+		return nil, &FilterError{Pos: pos, Msg: fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	if rest := data[dec.InputOffset():]; dec.Decode(&struct{}{}) != io.EOF {
+		pos := len(data) - len(bytes.TrimLeft(rest, " \t\r\n"))
+		return nil, &FilterError{Pos: pos, Msg: "invalid JSON: data after the document"}
+	}
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, &FilterError{Msg: "filter document must be a JSON object"}
+	}
+	d := &jsonFilterDecoder{spec: s}
+	return d.document(obj, "", 0)
+}
+
+type jsonFilterDecoder struct {
+	spec  FilterSpec
+	terms int
+}
+
+func (d *jsonFilterDecoder) errorf(path, format string, args ...interface{}) *FilterError {
+	return &FilterError{Path: path, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (d *jsonFilterDecoder) document(doc map[string]interface{}, path string, depth int) (Sqlizer, error) {
+	preds := And{}
+	for _, key := range sortedJSONKeys(doc) {
+		keyPath := joinJSONPath(path, key)
+		var pred Sqlizer
+		var err error
+		switch key {
+		case "$and", "$or":
+			pred, err = d.conj(key, doc[key], keyPath, depth)
+		default:
+			pred, err = d.field(key, doc[key], keyPath)
+		}
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, pred)
+	}
+	if len(preds) == 1 {
+		return preds[0], nil
+	}
+	return preds, nil
+}
+
+func (d *jsonFilterDecoder) conj(key string, value interface{}, path string, depth int) (Sqlizer, error) {
+	if depth >= d.spec.maxDepth {
+		return nil, d.errorf(path, "filter nested deeper than %d", d.spec.maxDepth)
+	}
+	docs, ok := value.([]interface{})
+	if !ok {
+		return nil, d.errorf(path, "%s needs an array of documents", key)
+	}
+	preds := make(conj, len(docs))
+	for i, doc := range docs {
+		docPath := path + "[" + strconv.Itoa(i) + "]"
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil, d.errorf(docPath, "%s needs an array of documents", key)
+		}
+		pred, err := d.document(obj, docPath, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		preds[i] = pred
+	}
+	if key == "$or" {
+		return Or(preds), nil
+	}
+	return And(preds), nil
+}
+
+func (d *jsonFilterDecoder) field(name string, value interface{}, path string) (Sqlizer, error) {
+	field, ok := d.spec.fields[name]
+	if !ok {
+		return nil, d.errorf(path, "unknown filter field %q", name)
+	}
+	ops, ok := value.(map[string]interface{})
+	if !ok {
+		v, err := d.value(field, value, path)
+		if err != nil {
+			return nil, err
+		}
+		return Eq{field.Column: v}, nil
+	}
+	if len(ops) == 0 {
+		return nil, d.errorf(path, "%q needs a value or operators", name)
+	}
+
+	var preds And
+	for _, op := range sortedJSONKeys(ops) {
+		pred, err := d.operator(name, field, op, ops[op], joinJSONPath(path, op))
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, pred)
+	}
+	if len(preds) == 1 {
+		return preds[0], nil
+	}
+	return preds, nil
+}
+
+func (d *jsonFilterDecoder) operator(name string, field FilterField, op string, value interface{}, path string) (Sqlizer, error) {
+	col := field.Column
+	switch op {
+	case "$null":
+		if err := d.term(path); err != nil {
+			return nil, err
+		}
+		isNull, ok := value.(bool)
+		if !ok {
+			return nil, d.errorf(path, "$null needs true or false")
+		}
+		if isNull {
+			return Eq{col: nil}, nil
+		}
+		return NotEq{col: nil}, nil
+	case "$in", "$nin":
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, d.errorf(path, "%s needs an array", op)
+		}
+		values := make([]interface{}, len(list))
+		for i, item := range list {
+			itemPath := path + "[" + strconv.Itoa(i) + "]"
+			v, err := d.value(field, item, itemPath)
+			if err != nil {
+				return nil, err
+			}
+			if v == nil {
+				return nil, d.errorf(itemPath, "null can't be in a list")
+			}
+			values[i] = v
+		}
+		if op == "$nin" {
+			return NotEq{col: values}, nil
+		}
+		return Eq{col: values}, nil
+	case "$eq", "$ne", "$gt", "$gte", "$lt", "$lte", "$like":
+	default:
+		return nil, d.errorf(path, "unknown operator %q", op)
+	}
+
+	if op == "$like" && field.Type != FilterString {
+		return nil, d.errorf(path, "$like needs a string field, %q is %s", name, field.Type)
+	}
+	v, err := d.value(field, value, path)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil && op != "$eq" && op != "$ne" {
+		return nil, d.errorf(path, "%s can't compare to null", op)
+	}
+	switch op {
+	case "$eq":
+		return Eq{col: v}, nil
+	case "$ne":
+		return NotEq{col: v}, nil
+	case "$gt":
+		return Gt{col: v}, nil
+	case "$gte":
+		return GtOrEq{col: v}, nil
+	case "$lt":
+		return Lt{col: v}, nil
+	case "$lte":
+		return LtOrEq{col: v}, nil
+	default:
+		return Like{col: v}, nil
+	}
+}
+
+// term counts a term of the filter against its limit.
+func (d *jsonFilterDecoder) term(path string) error {
+	d.terms++
+	if d.terms > d.spec.maxTerms {
+		return d.errorf(path, "filter has more than %d terms", d.spec.maxTerms)
+	}
+	return nil
+}
+
+// value coerces a JSON value to the type of field. A null value is returned as
+// nil.
+func (d *jsonFilterDecoder) value(field FilterField, value interface{}, path string) (interface{}, error) {
+	if err := d.term(path); err != nil {
+		return nil, err
+	}
+
+	var kind filterValueKind
+	var text string
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		kind, text = filterStringValue, v
+	case json.Number:
+		kind, text = filterNumberValue, v.String()
+	case bool:
+		kind, text = filterBoolValue, strconv.FormatBool(v)
+	default:
+		return nil, d.errorf(path, "invalid %s value", field.Type)
+	}
+	coerced, ok := coerceFilterValue(field.Type, kind, text)
+	if !ok {
+		return nil, d.errorf(path, "invalid %s value %s", field.Type, strconv.Quote(text))
+	}
+	return coerced, nil
+}
+
+// EncodeJSON encodes where, as decoded by DecodeJSON, back to a JSON filter
+// document, e.g. to save it.
+//
+// And and Or are encoded as $and and $or, and the keys of Eq, NotEq, Gt,
+// GtOrEq, Lt, LtOrEq and Like as fields. Their columns must be the Column of a
+// field of s, and their values encodable as JSON. Other Sqlizers can't be
+// encoded, including the NOT of filters parsed by Parse, which documents have
+// no operator for.
+//
+// EncodeJSON returns a *FilterError for filters it can't encode.
+func (s FilterSpec) EncodeJSON(where Sqlizer) ([]byte, error) {
+	names := make(map[safeString]string, len(s.fields))
+	for _, name := range sortedFilterFields(s.fields) {
+		if _, ok := names[s.fields[name].Column]; !ok {
+			names[s.fields[name].Column] = name
+		}
+	}
+	doc, err := encodeJSONFilter(where, names, "")
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+func encodeJSONError(path, format string, args ...interface{}) *FilterError {
+	return &FilterError{Pos: -1, Path: path, Msg: fmt.Sprintf(format, args...)}
+}
+
+func encodeJSONFilter(where Sqlizer, names map[safeString]string, path string) (map[string]interface{}, error) {
+	var (
+		key   string
+		preds conj
+		exprs map[safeString]interface{}
+		op    string
+	)
+	switch w := where.(type) {
+	case And:
+		key, preds = "$and", conj(w)
+	case Or:
+		key, preds = "$or", conj(w)
+	case Eq:
+		exprs, op = w, "$eq"
+	case NotEq:
+		exprs, op = w, "$ne"
+	case Gt:
+		exprs, op = w, "$gt"
+	case GtOrEq:
+		exprs, op = w, "$gte"
+	case Lt:
+		exprs, op = w, "$lt"
+	case LtOrEq:
+		exprs, op = w, "$lte"
+	case Like:
+		exprs, op = w, "$like"
+	case expr:
+		if w.sql == "NOT (?)" {
+			// Parse's not: documents have no operator for it.
+			return nil, encodeJSONError(path, "cannot encode NOT as a JSON filter")
+		}
+		return nil, encodeJSONError(path, "cannot encode %T as a JSON filter", where)
+	default:
+		return nil, encodeJSONError(path, "cannot encode %T as a JSON filter", where)
+	}
+
+	if key != "" {
+		keyPath := joinJSONPath(path, key)
+		docs := make([]interface{}, len(preds))
+		for i, pred := range preds {
+			doc, err := encodeJSONFilter(pred, names, keyPath+"["+strconv.Itoa(i)+"]")
+			if err != nil {
+				return nil, err
+			}
+			docs[i] = doc
+		}
+		return map[string]interface{}{key: docs}, nil
+	}
+
+	doc := make(map[string]interface{}, len(exprs))
+	for col, value := range exprs {
+		name, ok := names[col]
+		if !ok {
+			return nil, encodeJSONError(path, "column %s is not a filter field", col)
+		}
+		encoded, err := encodeJSONOperator(op, value, joinJSONPath(path, name))
+		if err != nil {
+			return nil, err
+		}
+		doc[name] = encoded
+	}
+	return doc, nil
+}
+
+func encodeJSONOperator(op string, value interface{}, path string) (interface{}, error) {
+	isList := value != nil && isListType(value)
+	if isList {
+		switch op {
+		case "$eq", "$ne":
+			listOp := "$in"
+			if op == "$ne" {
+				listOp = "$nin"
+			}
+			list := reflect.ValueOf(value)
+			for i := 0; i < list.Len(); i++ {
+				itemPath := joinJSONPath(path, listOp) + "[" + strconv.Itoa(i) + "]"
+				if err := checkJSONFilterValue(list.Index(i).Interface(), itemPath); err != nil {
+					return nil, err
+				}
+			}
+			return map[string]interface{}{listOp: value}, nil
+		}
+	}
+	if value == nil || reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil() {
+		switch op {
+		case "$eq":
+			return nil, nil
+		case "$ne":
+			return map[string]interface{}{"$null": false}, nil
+		}
+	}
+	if op == "$eq" {
+		return value, checkJSONFilterValue(value, path)
+	}
+	return map[string]interface{}{op: value}, checkJSONFilterValue(value, joinJSONPath(path, op))
+}
+
+// checkJSONFilterValue checks that value can be encoded as a JSON number, as
+// json.Marshal can't encode NaN and infinite floats.
+func checkJSONFilterValue(value interface{}, path string) error {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Float32 && v.Kind() != reflect.Float64 {
+		return nil
+	}
+	if f := v.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+		return encodeJSONError(path, "cannot encode %v as a JSON number", f)
+	}
+	return nil
+}
+
+func sortedJSONKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedFilterFields(fields FilterFields) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func joinJSONPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
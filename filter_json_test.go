@@ -0,0 +1,153 @@
+package squirrel2
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterSpecDecodeJSON(t *testing.T) {
+	where, err := testFilter.DecodeJSON([]byte(`{
+		"status": "active",
+		"$or": [{"age": {"$gte": 18, "$lt": 65}}, {"vip": true}]
+	}`))
+	assert.NoError(t, err)
+	assert.Equal(t, And{
+		Or{And{GtOrEq{"users.age": int64(18)}, Lt{"users.age": int64(65)}}, Eq{"users.vip": true}},
+		Eq{"users.status": "active"},
+	}, where)
+
+	sql, args, err := Select("*").From("users").Where(where).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM users WHERE (((users.age >= ? AND users.age < ?) OR users.vip = ?) AND users.status = ?)", sql)
+	assert.Equal(t, []interface{}{int64(18), int64(65), true, "active"}, args)
+}
+
+func TestFilterSpecDecodeJSONOperators(t *testing.T) {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for doc, expected := range map[string]Sqlizer{
+		`{}`:                                     And{},
+		`{"status": {"$eq": "a", "$ne": "b"}}`:   And{Eq{"users.status": "a"}, NotEq{"users.status": "b"}},
+		`{"status": {"$like": "a%"}}`:            Like{"users.status": "a%"},
+		`{"status": null}`:                       Eq{"users.status": nil},
+		`{"status": {"$null": true}}`:            Eq{"users.status": nil},
+		`{"status": {"$null": false}}`:           NotEq{"users.status": nil},
+		`{"age": {"$in": [1, "2"]}}`:             Eq{"users.age": []interface{}{int64(1), int64(2)}},
+		`{"age": {"$nin": []}}`:                  NotEq{"users.age": []interface{}{}},
+		`{"age": {"$gt": 1, "$lte": 9}}`:         And{Gt{"users.age": int64(1)}, LtOrEq{"users.age": int64(9)}},
+		`{"score": 1.5e3}`:                       Eq{"users.score": 1.5e3},
+		`{"created_at": {"$gte": "2024-05-01"}}`: GtOrEq{"users.created_at": day},
+		`{"$and": [{"vip": false}], "$or": []}`:  And{And{Eq{"users.vip": false}}, Or{}},
+	} {
+		where, err := testFilter.DecodeJSON([]byte(doc))
+		if assert.NoError(t, err, doc) {
+			assert.Equal(t, expected, where, doc)
+		}
+	}
+}
+
+func TestFilterSpecDecodeJSONErrors(t *testing.T) {
+	for doc, msg := range map[string]string{
+		`{"password": "x"}`:                 `unknown filter field "password" at password`,
+		`{"$or": [{"age": {"$gte": "x"}}]}`: `invalid integer value "x" at $or[0].age.$gte`,
+		`{"age": {"$in": [1, null]}}`:       `null can't be in a list at age.$in[1]`,
+		`{"age": {"$in": 1}}`:               `$in needs an array at age.$in`,
+		`{"age": {"$like": "1%"}}`:          `$like needs a string field, "age" is integer at age.$like`,
+		`{"age": {"$gt": null}}`:            `$gt can't compare to null at age.$gt`,
+		`{"age": {"$regex": "x"}}`:          `unknown operator "$regex" at age.$regex`,
+		`{"age": {}}`:                       `"age" needs a value or operators at age`,
+		`{"age": [1]}`:                      `invalid integer value at age`,
+		`{"vip": {"$null": 1}}`:             `$null needs true or false at vip.$null`,
+		`{"$and": {"age": 1}}`:              `$and needs an array of documents at $and`,
+		`{"$or": [1]}`:                      `$or needs an array of documents at $or[0]`,
+		`[]`:                                `filter document must be a JSON object at position 0`,
+		`{"age": 1} {}`:                     `invalid JSON: data after the document at position 11`,
+		`{"age": }`:                         `invalid JSON: invalid character '}' looking for beginning of value at position 8`,
+		`{"age": 1`:                         `invalid JSON: unexpected EOF at position 9`,
+	} {
+		_, err := testFilter.DecodeJSON([]byte(doc))
+		var filterErr *FilterError
+		if assert.ErrorAs(t, err, &filterErr, doc) {
+			assert.Equal(t, msg, err.Error(), doc)
+		}
+	}
+
+	spec := testFilter.MaxDepth(1).MaxTerms(2)
+	_, err := spec.DecodeJSON([]byte(`{"$or": [{"$and": [{"age": 1}]}]}`))
+	assert.EqualError(t, err, "filter nested deeper than 1 at $or[0].$and")
+	_, err = spec.DecodeJSON([]byte(`{"age": {"$in": [1, 2, 3]}}`))
+	assert.EqualError(t, err, "filter has more than 2 terms at age.$in[2]")
+	_, err = spec.DecodeJSON([]byte(`{"age": {"$null": false}, "status": {"$null": true}, "vip": {"$null": true}}`))
+	assert.EqualError(t, err, "filter has more than 2 terms at vip.$null")
+	_, err = spec.MaxLength(4).DecodeJSON([]byte(`{"age": 1}`))
+	assert.EqualError(t, err, "filter longer than 4 bytes at position 4")
+}
+
+func TestFilterSpecEncodeJSON(t *testing.T) {
+	for _, doc := range []string{
+		`{}`,
+		`{"$and":[]}`,
+		`{"status":"active"}`,
+		`{"status":null}`,
+		`{"status":{"$null":false}}`,
+		`{"status":{"$like":"a%"}}`,
+		`{"age":{"$in":[1,2]}}`,
+		`{"age":{"$nin":[3]}}`,
+		`{"$or":[{"age":{"$gte":18}},{"vip":true}]}`,
+		`{"$and":[{"age":{"$gt":1}},{"age":{"$lte":9}},{"created_at":{"$lt":"2024-05-01T10:00:00Z"}}]}`,
+		`{"$and":[{"$or":[{"score":{"$ne":1.5}}]}]}`,
+	} {
+		where, err := testFilter.DecodeJSON([]byte(doc))
+		if !assert.NoError(t, err, doc) {
+			continue
+		}
+		data, err := testFilter.EncodeJSON(where)
+		if assert.NoError(t, err, doc) {
+			// Documents decoded from the encoding of a filter decode to the
+			// same filter.
+			roundTrip, err := testFilter.DecodeJSON(data)
+			assert.NoError(t, err, doc)
+			assert.Equal(t, where, roundTrip, doc)
+		}
+	}
+
+	data, err := testFilter.EncodeJSON(And{Eq{"users.status": "a", "users.age": 1}, Or{}})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"$and":[{"age":1,"status":"a"},{"$or":[]}]}`, string(data))
+
+	_, err = testFilter.EncodeJSON(Eq{"users.password": "x"})
+	assert.EqualError(t, err, "column users.password is not a filter field")
+	_, err = testFilter.EncodeJSON(And{Expr("x = 1")})
+	assert.EqualError(t, err, "cannot encode squirrel2.expr as a JSON filter at $and[0]")
+}
+
+func TestFilterSpecEncodeJSONErrors(t *testing.T) {
+	for filter, msg := range map[string]string{
+		"not vip eq true":                  "cannot encode NOT as a JSON filter",
+		"age gt 1 and not (status eq 'a')": "cannot encode NOT as a JSON filter at $and[1]",
+	} {
+		where, err := testFilter.Parse(filter)
+		if !assert.NoError(t, err, filter) {
+			continue
+		}
+		_, err = testFilter.EncodeJSON(where)
+		var filterErr *FilterError
+		if assert.ErrorAs(t, err, &filterErr, filter) {
+			assert.Equal(t, msg, err.Error(), filter)
+		}
+	}
+
+	for msg, where := range map[string]Sqlizer{
+		"cannot encode NaN as a JSON number at score":             Eq{"users.score": math.NaN()},
+		"cannot encode +Inf as a JSON number at $or[0].score.$gt": Or{Gt{"users.score": math.Inf(1)}},
+		"cannot encode -Inf as a JSON number at score.$nin[1]":    NotEq{"users.score": []float64{1, math.Inf(-1)}},
+	} {
+		_, err := testFilter.EncodeJSON(where)
+		var filterErr *FilterError
+		if assert.ErrorAs(t, err, &filterErr, msg) {
+			assert.Equal(t, msg, err.Error())
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package squirrel2
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testFilter = NewFilterSpec(FilterFields{
+	"status":     {Column: "users.status"},
+	"age":        {Column: "users.age", Type: FilterInt},
+	"score":      {Column: "users.score", Type: FilterFloat},
+	"vip":        {Column: "users.vip", Type: FilterBool},
+	"created_at": {Column: "users.created_at", Type: FilterTime},
+})
+
+func TestFilterSpecParse(t *testing.T) {
+	where, err := testFilter.Parse("status eq 'active' and (age gt 30 or vip eq true)")
+	assert.NoError(t, err)
+	assert.Equal(t, And{Eq{"users.status": "active"}, Or{Gt{"users.age": int64(30)}, Eq{"users.vip": true}}}, where)
+
+	sql, args, err := Select("*").From("users").Where(where).PlaceholderFormat(Dollar).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM users WHERE (users.status = $1 AND (users.age > $2 OR users.vip = $3))", sql)
+	assert.Equal(t, []interface{}{"active", int64(30), true}, args)
+}
+
+func TestFilterSpecOperators(t *testing.T) {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for filter, expected := range map[string]Sqlizer{
+		"status ne 'it''s'":                    NotEq{"users.status": "it's"},
+		"age GE '18' AND age Le 65":            And{GtOrEq{"users.age": int64(18)}, LtOrEq{"users.age": int64(65)}},
+		"score lt -1.5e3":                      Lt{"users.score": -1.5e3},
+		"status like 'a%'":                     Like{"users.status": "a%"},
+		"age in (1, '2',3)":                    Eq{"users.age": []interface{}{int64(1), int64(2), int64(3)}},
+		"status eq null":                       Eq{"users.status": nil},
+		"status ne NULL":                       NotEq{"users.status": nil},
+		"vip eq 'false'":                       Eq{"users.vip": false},
+		"created_at ge '2024-05-01'":           GtOrEq{"users.created_at": day},
+		"created_at lt '2024-05-01T00:00:00Z'": Lt{"users.created_at": day},
+		"((age eq 1))":                         Eq{"users.age": int64(1)},
+		"not (age eq 1 or age eq 2)":           Expr("NOT (?)", Or{Eq{"users.age": int64(1)}, Eq{"users.age": int64(2)}}),
+		"  ":                                   And{},
+	} {
+		where, err := testFilter.Parse(filter)
+		if assert.NoError(t, err, filter) {
+			assert.Equal(t, expected, where, filter)
+		}
+	}
+
+	where, err := testFilter.Parse("not vip eq true")
+	assert.NoError(t, err)
+	sql, args, err := where.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "NOT (users.vip = ?)", sql)
+	assert.Equal(t, []interface{}{true}, args)
+}
+
+func TestFilterSpecErrors(t *testing.T) {
+	for filter, msg := range map[string]string{
+		"password eq 'x'":           `unknown filter field "password" at position 0`,
+		"age eq 'x'":                `invalid integer value string "x" at position 7`,
+		"age eq 1.5":                `invalid integer value "1.5" at position 7`,
+		"status eq 1":               `invalid string value "1" at position 10`,
+		"vip eq 1":                  `invalid boolean value "1" at position 7`,
+		"created_at eq 'yesterday'": `invalid time value string "yesterday" at position 14`,
+		"age like '1%'":             `like needs a string field, "age" is integer at position 4`,
+		"age gt null":               `gt can't compare to null at position 4`,
+		"age in (1, null)":          `null can't be in a list at position 4`,
+		"age in (1 2)":              `expected "," or ")", found "2" at position 10`,
+		"age is 1":                  `unknown operator "is" at position 4`,
+		"age eq":                    `expected value, found end of filter at position 6`,
+		"age":                       `expected operator, found end of filter at position 3`,
+		"(age eq 1":                 `expected ")", found end of filter at position 9`,
+		"age eq 1)":                 `unexpected ")" at position 8`,
+		"age eq 1 age eq 2":         `unexpected "age" at position 9`,
+		"and age eq 1":              `unknown filter field "and" at position 0`,
+		"status eq 'x":              `unterminated string at position 10`,
+		"status eq \"x\"":           `unexpected character '"' at position 10`,
+		"'x' eq status":             `expected field, found string "x" at position 0`,
+	} {
+		_, err := testFilter.Parse(filter)
+		var filterErr *FilterError
+		if assert.ErrorAs(t, err, &filterErr, filter) {
+			assert.Equal(t, msg, err.Error(), filter)
+		}
+	}
+}
+
+func TestFilterSpecLimits(t *testing.T) {
+	spec := testFilter.MaxDepth(2).MaxTerms(3).MaxLength(40)
+
+	_, err := spec.Parse("((age eq 1))")
+	assert.NoError(t, err)
+	_, err = spec.Parse("not ((age eq 1))")
+	assert.EqualError(t, err, "filter nested deeper than 2 at position 5")
+
+	_, err = spec.Parse("age eq 1 or age eq 2 or age eq 3")
+	assert.NoError(t, err)
+	_, err = spec.Parse("age in (1, 2) or age in (3, 4)")
+	assert.EqualError(t, err, "filter has more than 3 terms at position 28")
+
+	_, err = spec.Parse("status eq '" + strings.Repeat("x", 40) + "'")
+	assert.EqualError(t, err, "filter longer than 40 bytes at position 40")
+
+	// Deep nesting is rejected before it can exhaust the stack.
+	deep := strings.Repeat("(", 100000)
+	_, err = NewFilterSpec(FilterFields{}).MaxLength(len(deep)).Parse(deep)
+	assert.EqualError(t, err, "filter nested deeper than 8 at position 8")
+}
@@ -0,0 +1,106 @@
+package squirrel2
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InlineSql renders s as a single SQL string with each of its args inlined as
+// a literal of dialect d, e.g. for logging or for EXPLAIN tooling.
+//
+// Values are converted like database/sql does before they're handed to a
+// driver, so driver.Valuer implementations are supported. InlineSql returns an
+// error rather than guessing when a value can't be safely inlined, e.g. a NaN
+// float, a string holding a NUL byte or a type unknown to database/sql.
+//
+// Even though the result is properly escaped, prefer executing s with its
+// args: placeholders let the database reuse query plans and drivers encode
+// values exactly.
+func InlineSql(s Sqlizer, d Dialect) (string, error) {
+	sql, args, err := nestedToSql(s)
+	if err != nil {
+		return "", err
+	}
+	return inlineArgs(sql, args, d)
+}
+
+// inlineArgs replaces each "?" placeholder of the raw sql with the matching
+// arg rendered as a literal of d.
+func inlineArgs(sql string, args []interface{}, d Dialect) (string, error) {
+	ld, ok := d.(literalDialect)
+	if !ok {
+		ld = ANSI
+	}
+	_, isPostgres := d.(postgresDialect)
+	_, isMySQL := d.(mysqlDialect)
+
+	buf := &bytes.Buffer{}
+	i := 0
+	err := sqlLexer{jsonbOperators: isPostgres, backslashEscapes: isMySQL}.scan(sql, func(tok sqlToken) error {
+		switch tok.kind {
+		case sqlPlaceholder:
+			if i >= len(args) {
+				return fmt.Errorf("too many placeholders in %#v for %d args", sql, len(args))
+			}
+			literal, err := inlineValue(ld, args[i])
+			if err != nil {
+				return fmt.Errorf("cannot inline arg %d: %w", i+1, err)
+			}
+			buf.WriteString(literal)
+			i++
+		case sqlEscapedPlaceholder:
+			buf.WriteString("?")
+		default:
+			buf.WriteString(tok.text)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if i < len(args) {
+		return "", fmt.Errorf("not enough placeholders in %#v for %d args", sql, len(args))
+	}
+	return buf.String(), nil
+}
+
+// inlineValue renders arg as a SQL literal.
+func inlineValue(d literalDialect, arg interface{}) (string, error) {
+	if na, ok := arg.(namedArg); ok {
+		arg = na.value
+	}
+	value, err := driver.DefaultParameterConverter.ConvertValue(arg)
+	if err != nil {
+		return "", err
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		return d.quoteBool(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("%v has no portable SQL literal", v)
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case string:
+		if strings.IndexByte(v, 0) >= 0 {
+			return "", fmt.Errorf("strings holding NUL bytes can't be safely inlined")
+		}
+		return d.quoteString(v), nil
+	case []byte:
+		return d.quoteBytes(v), nil
+	case time.Time:
+		return d.quoteTime(v), nil
+	default:
+		return "", fmt.Errorf("unsupported type %T", value)
+	}
+}
@@ -0,0 +1,90 @@
+package squirrel2
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInlineSql(t *testing.T) {
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 500000000, time.FixedZone("", -3*60*60))
+	var nilPtr *int
+	b := Select("*").From("t").
+		Where(Eq{"s": `it's \ here`}).
+		Where(Eq{"n": nil, "p": nilPtr}).
+		Where(Expr("b = ? AND f = ? AND i = ? AND u = ?", true, 1.5, -3, uint8(4))).
+		Where(Expr("bin = ? AND at = ?", []byte{0xde, 0xad}, ts)).
+		Where(Expr("ns = ? AND ns2 = ?", sql.NullString{}, sql.NullString{String: "x", Valid: true})).
+		Where(Expr("q = '?' AND data ?? 'k'")).
+		PlaceholderFormat(Dollar)
+
+	tests := []struct {
+		dialect  Dialect
+		expected string
+	}{
+		{ANSI, `SELECT * FROM t WHERE s = 'it''s \ here' AND n IS NULL AND p IS NULL AND ` +
+			`b = TRUE AND f = 1.5 AND i = -3 AND u = 4 AND ` +
+			`bin = X'dead' AND at = '2024-05-06 07:08:09.5-03:00' AND ns = NULL AND ns2 = 'x' AND ` +
+			`q = '?' AND data ? 'k'`},
+		{Postgres, `SELECT * FROM t WHERE s = 'it''s \ here' AND n IS NULL AND p IS NULL AND ` +
+			`b = TRUE AND f = 1.5 AND i = -3 AND u = 4 AND ` +
+			`bin = '\xdead'::bytea AND at = '2024-05-06 07:08:09.5-03:00' AND ns = NULL AND ns2 = 'x' AND ` +
+			`q = '?' AND data ? 'k'`},
+		{SQLite, `SELECT * FROM t WHERE s = 'it''s \ here' AND n IS NULL AND p IS NULL AND ` +
+			`b = 1 AND f = 1.5 AND i = -3 AND u = 4 AND ` +
+			`bin = X'dead' AND at = '2024-05-06 07:08:09.5-03:00' AND ns = NULL AND ns2 = 'x' AND ` +
+			`q = '?' AND data ? 'k'`},
+		{MySQL, `SELECT * FROM t WHERE s = 'it''s \\ here' AND n IS NULL AND p IS NULL AND ` +
+			`b = TRUE AND f = 1.5 AND i = -3 AND u = 4 AND ` +
+			`bin = X'dead' AND at = '2024-05-06 10:08:09.5' AND ns = NULL AND ns2 = 'x' AND ` +
+			`q = '?' AND data ? 'k'`},
+		{SQLServer, `SELECT * FROM t WHERE s = N'it''s \ here' AND n IS NULL AND p IS NULL AND ` +
+			`b = 1 AND f = 1.5 AND i = -3 AND u = 4 AND ` +
+			`bin = 0xdead AND at = '2024-05-06T07:08:09.5-03:00' AND ns = NULL AND ns2 = N'x' AND ` +
+			`q = '?' AND data ? 'k'`},
+	}
+	for _, test := range tests {
+		sql, err := InlineSql(b, test.dialect)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, sql)
+	}
+}
+
+func TestInlineSqlNamed(t *testing.T) {
+	b := Select("*").From("t").
+		Where(Expr("a = :v OR b = :v", map[string]interface{}{"v": "x"})).
+		PlaceholderFormat(Dollar)
+	sql, err := InlineSql(b, Postgres)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE a = 'x' OR b = 'x'", sql)
+}
+
+func TestInlineSqlMySQLBackslashEscapes(t *testing.T) {
+	b := Select("*").From("t").Where(Expr(`a = 'it\'s ?' AND b = ?`, 1))
+	sql, err := InlineSql(b, MySQL)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM t WHERE a = 'it\'s ?' AND b = 1`, sql)
+}
+
+func TestInlineSqlErrors(t *testing.T) {
+	_, err := InlineSql(Expr("x = ?", math.NaN()), ANSI)
+	assert.Error(t, err)
+
+	_, err = InlineSql(Expr("x = ?", "nul\x00"), ANSI)
+	assert.Error(t, err)
+
+	_, err = InlineSql(Expr("x = ?", struct{}{}), ANSI)
+	assert.Error(t, err)
+
+	_, err = InlineSql(Expr("x = ?", 1, 2), ANSI)
+	assert.Error(t, err)
+
+	_, err = InlineSql(Expr("x = ? AND y = ?", 1), ANSI)
+	assert.Error(t, err)
+
+	_, err = InlineSql(Select(), ANSI)
+	assert.Error(t, err)
+}
@@ -2,17 +2,22 @@ package squirrel2
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"io"
 	"sort"
 	"strings"
+	"time"
 )
 
 type insertData struct {
 	PlaceholderFormat PlaceholderFormat
 	RunWith           BaseRunner
+	Timeout           time.Duration
+	Tags              sqlTags
+	ExpectRollback    bool
 	Prefixes          []Sqlizer
 	StatementKeyword  safeString
 	Options           []safeString
@@ -27,6 +32,9 @@ func (d *insertData) Exec() (sql.Result, error) {
 	if d.RunWith == nil {
 		return nil, ErrRunnerNotSet
 	}
+	if d.Timeout > 0 {
+		return d.ExecContext(context.Background())
+	}
 	return ExecWith(d.RunWith, d)
 }
 
@@ -34,6 +42,9 @@ func (d *insertData) Query() (*sql.Rows, error) {
 	if d.RunWith == nil {
 		return nil, ErrRunnerNotSet
 	}
+	if d.Timeout > 0 {
+		return d.QueryContext(context.Background())
+	}
 	return QueryWith(d.RunWith, d)
 }
 
@@ -41,6 +52,9 @@ func (d *insertData) QueryRow() RowScanner {
 	if d.RunWith == nil {
 		return &Row{err: ErrRunnerNotSet}
 	}
+	if d.Timeout > 0 {
+		return d.QueryRowContext(context.Background())
+	}
 	queryRower, ok := d.RunWith.(QueryRower)
 	if !ok {
 		return &Row{err: ErrRunnerNotQueryRunner}
@@ -49,6 +63,16 @@ func (d *insertData) QueryRow() RowScanner {
 }
 
 func (d *insertData) ToSql() (sqlStr string, args []interface{}, err error) {
+	sqlStr, args, err = d.toSqlRaw()
+	if err != nil {
+		return
+	}
+
+	sqlStr, args, err = replacePlaceholders(d.PlaceholderFormat, sqlStr, args)
+	return
+}
+
+func (d *insertData) toSqlRaw() (sqlStr string, args []interface{}, err error) {
 	if len(d.Into) == 0 {
 		err = errors.New("insert statements must specify a table")
 		return
@@ -115,7 +139,8 @@ func (d *insertData) ToSql() (sqlStr string, args []interface{}, err error) {
 		}
 	}
 
-	sqlStr, err = d.PlaceholderFormat.ReplacePlaceholders(sql.String())
+	// The tags go with the statement, even when it is nested in another.
+	sqlStr = d.Tags.apply(sql.String())
 	return
 }
 
@@ -131,7 +156,7 @@ func (d *insertData) appendValuesToSQL(w io.Writer, args []interface{}) ([]inter
 		valueStrings := make([]string, len(row))
 		for v, val := range row {
 			if vs, ok := val.(Sqlizer); ok {
-				vsql, vargs, err := vs.ToSql()
+				vsql, vargs, err := nestedToSql(vs)
 				if err != nil {
 					return nil, err
 				}
@@ -155,7 +180,7 @@ func (d *insertData) appendSelectToSQL(w io.Writer, args []interface{}) ([]inter
 		return args, errors.New("select clause for insert statements are not set")
 	}
 
-	selectClause, sArgs, err := d.Select.ToSql()
+	selectClause, sArgs, err := d.Select.toSqlRaw()
 	if err != nil {
 		return args, err
 	}
@@ -178,6 +203,8 @@ func InsertBuilder(b statementBuilderType) insertBuilder {
 		data: insertData{
 			PlaceholderFormat: b.placeholderFormat,
 			RunWith:           b.runWith,
+			Timeout:           b.timeout,
+			Tags:              b.tags,
 			Prefixes:          make([]Sqlizer, 0),
 			Options:           make([]safeString, 0),
 			Columns:           make([]safeString, 0),
@@ -217,6 +244,39 @@ func (b insertBuilder) RunWith(runner BaseRunner) insertBuilder {
 	return b
 }
 
+// Timeout sets the time the statement may run for.
+//
+// See SelectBuilder.Timeout for more information.
+func (b insertBuilder) Timeout(d time.Duration) insertBuilder {
+	b.data.Timeout = d
+	return b
+}
+
+// Tag adds a tag to the comment of the statement.
+//
+// See SelectBuilder.Tag for more information.
+func (b insertBuilder) Tag(key, value string) insertBuilder {
+	b.data.Tags = b.data.Tags.with(key, value)
+	return b
+}
+
+// TagPosition sets where the comment holding the tags goes.
+//
+// See SelectBuilder.TagPosition for more information.
+func (b insertBuilder) TagPosition(p TagPosition) insertBuilder {
+	b.data.Tags.position = p
+	return b
+}
+
+// RollbackOnUnexpected makes ExecExpect and ExecExpectRange roll back the
+// transaction or savepoint they run with when their expectation fails.
+//
+// See UpdateBuilder.RollbackOnUnexpected for more information.
+func (b insertBuilder) RollbackOnUnexpected() insertBuilder {
+	b.data.ExpectRollback = true
+	return b
+}
+
 // Exec builds and Execs the query with the Runner set by RunWith.
 func (b insertBuilder) Exec() (sql.Result, error) {
 	return b.data.Exec()
@@ -244,6 +304,10 @@ func (b insertBuilder) ToSql() (string, []interface{}, error) {
 	return b.data.ToSql()
 }
 
+func (b insertBuilder) toSqlRaw() (string, []interface{}, error) {
+	return b.data.toSqlRaw()
+}
+
 // MustSql builds the query into a SQL string and bound args.
 // It panics if there are any errors.
 func (b insertBuilder) MustSql() (string, []interface{}) {
@@ -16,6 +16,10 @@ func (d *insertData) ExecContext(ctx context.Context) (sql.Result, error) {
 	if !ok {
 		return nil, ErrNoContextSupport
 	}
+	d = d.withContextTags(ctx)
+	if d.Timeout > 0 {
+		return execContextTimeout(ctx, d.Timeout, ctxRunner, d)
+	}
 	return ExecContextWith(ctx, ctxRunner, d)
 }
 
@@ -27,6 +31,10 @@ func (d *insertData) QueryContext(ctx context.Context) (*sql.Rows, error) {
 	if !ok {
 		return nil, ErrNoContextSupport
 	}
+	d = d.withContextTags(ctx)
+	if d.Timeout > 0 {
+		return queryContextTimeout(ctx, d.Timeout, ctxRunner, d)
+	}
 	return QueryContextWith(ctx, ctxRunner, d)
 }
 
@@ -41,6 +49,10 @@ func (d *insertData) QueryRowContext(ctx context.Context) RowScanner {
 		}
 		return &Row{err: ErrNoContextSupport}
 	}
+	d = d.withContextTags(ctx)
+	if d.Timeout > 0 {
+		return queryRowContextTimeout(ctx, d.Timeout, queryRower, d)
+	}
 	return QueryRowContextWith(ctx, queryRower, d)
 }
 
@@ -63,3 +75,30 @@ func (b insertBuilder) QueryRowContext(ctx context.Context) RowScanner {
 func (b insertBuilder) ScanContext(ctx context.Context, dest ...interface{}) error {
 	return b.QueryRowContext(ctx).Scan(dest...)
 }
+
+// ExecExpect builds and ExecContexts the query with the Runner set by RunWith,
+// and checks that it affected exactly n rows.
+//
+// See UpdateBuilder.ExecExpect for more information.
+func (b insertBuilder) ExecExpect(ctx context.Context, n int64) (sql.Result, error) {
+	return execExpect(ctx, b.data.ExecContext, b.data.RunWith, n, n, b.data.ExpectRollback)
+}
+
+// ExecExpectRange is like ExecExpect, but checks that the query affected min
+// to max rows.
+//
+// See UpdateBuilder.ExecExpectRange for more information.
+func (b insertBuilder) ExecExpectRange(ctx context.Context, min, max int64) (sql.Result, error) {
+	return execExpect(ctx, b.data.ExecContext, b.data.RunWith, min, max, b.data.ExpectRollback)
+}
+
+// withContextTags returns d, or a copy of it that also has the tags of ctx.
+func (d *insertData) withContextTags(ctx context.Context) *insertData {
+	tags := ContextTags(ctx)
+	if len(tags) == 0 {
+		return d
+	}
+	tagged := *d
+	tagged.Tags = d.Tags.withDefaults(tags)
+	return &tagged
+}
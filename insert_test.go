@@ -110,3 +110,19 @@ func TestInsertBuilderReplace(t *testing.T) {
 
 	assert.Equal(t, expectedSQL, sql)
 }
+
+func TestInsertBuilderSelectDollar(t *testing.T) {
+	sb := Select("field1").From("table1").Where(Eq{"field1": 1}).PlaceholderFormat(Dollar)
+	ib := Insert("table2").
+		Columns("field1").
+		Select(sb).
+		Suffix("RETURNING ?", 2).
+		PlaceholderFormat(Dollar)
+
+	sql, args, err := ib.ToSql()
+	assert.NoError(t, err)
+
+	expectedSQL := "INSERT INTO table2 (field1) SELECT field1 FROM table1 WHERE field1 = $1 RETURNING $2"
+	assert.Equal(t, expectedSQL, sql)
+	assert.Equal(t, []interface{}{1, 2}, args)
+}
@@ -2,16 +2,19 @@ module github.com/cauanvital/squirrel2/integration
 
 go 1.24.5
 
+require (
+	github.com/cauanvital/squirrel2 v0.0.0
+	github.com/go-sql-driver/mysql v1.9.3
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.32
+	github.com/stretchr/testify v1.11.1
+)
+
 require (
 	filippo.io/edwards25519 v1.1.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/go-sql-driver/mysql v1.9.3 // indirect
-	github.com/lib/pq v1.10.9 // indirect
-	github.com/mattn/go-sqlite3 v1.14.32 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/stretchr/testify v1.11.1 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
-    github.com/cauanvital/squirrel2 v0.0.0
 )
 
-replace github.com/cauanvital/squirrel => ../
+replace github.com/cauanvital/squirrel2 => ../
@@ -30,7 +30,8 @@ const (
 )
 
 var (
-	sb = sqrl.StatementBuilder
+	sb     = sqrl.StatementBuilder
+	testDB *sql.DB
 )
 
 type selectBuilder interface {
@@ -56,6 +57,10 @@ func TestMain(m *testing.M) {
 		fmt.Printf("error opening database: %v\n", err)
 		os.Exit(-1)
 	}
+	if dataSource == ":memory:" {
+		// Every connection to :memory: opens a new, empty database.
+		db.SetMaxOpenConns(1)
+	}
 
 	_, err = db.Exec(testSchema)
 	if err != nil {
@@ -74,6 +79,7 @@ func TestMain(m *testing.M) {
 		os.Exit(-3)
 	}
 
+	testDB = db
 	sb = sqrl.StatementBuilder.RunWith(db)
 
 	if driver == "postgres" {
@@ -134,6 +140,7 @@ func TestConj(t *testing.T) {
 func TestContext(t *testing.T) {
 	s := sb.Select("v").From("squirrel_integration")
 	ctx := context.Background()
-	_, err := s.QueryContext(ctx)
+	rows, err := s.QueryContext(ctx)
 	assert.NoError(t, err)
+	rows.Close()
 }
@@ -0,0 +1,54 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	sqrl "github.com/cauanvital/squirrel2"
+)
+
+func TestNestedTx(t *testing.T) {
+	ctx := context.Background()
+	errInner := errors.New("inner failed")
+	insert := func(tx sqrl.RunnerContext, k int, v string) error {
+		_, err := sb.RunWith(tx).Insert("squirrel_integration").Values(k, v).ExecContext(ctx)
+		return err
+	}
+
+	var vals []string
+	err := sqrl.WithTx(ctx, testDB, nil, func(tx sqrl.RunnerContext) error {
+		if err := insert(tx, 10, "outer"); err != nil {
+			return err
+		}
+		err := tx.(*sqrl.Tx).WithTx(ctx, func(tx sqrl.RunnerContext) error {
+			if err := insert(tx, 11, "released"); err != nil {
+				return err
+			}
+			err := tx.(*sqrl.Tx).WithTx(ctx, func(tx sqrl.RunnerContext) error {
+				if err := insert(tx, 12, "rolled back"); err != nil {
+					return err
+				}
+				return errInner
+			})
+			assert.Equal(t, errInner, err)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		txb := sb.RunWith(tx)
+		vals, err = sqrl.ScanAll[string](ctx,
+			txb.Select("v").From("squirrel_integration").Where(sqrl.GtOrEq{"k": 10}).OrderBy("k"))
+		if err != nil {
+			return err
+		}
+		_, err = txb.Delete("squirrel_integration").Where(sqrl.GtOrEq{"k": 10}).ExecContext(ctx)
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"outer", "released"}, vals)
+}
@@ -0,0 +1,240 @@
+package squirrel2
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// QueryInfo describes a statement run by a runner returned by WrapRunner.
+type QueryInfo struct {
+	// Method is the name of the runner method, without its Context suffix:
+	// "Exec", "Query" or "QueryRow".
+	Method string
+	// SQL and Args are the statement and its args. Interceptors may change
+	// them in Before.
+	SQL  string
+	Args []interface{}
+
+	// Start is the time the first Before was called.
+	Start time.Time
+	// Duration is the time the statement took, including the Scan of a
+	// QueryRow. It's set before After is called.
+	Duration time.Duration
+	// Err is the error the statement failed with, if any. It's set before
+	// After is called.
+	Err error
+	// RowsAffected is the number of rows affected by an Exec, or -1 if it's
+	// unknown. It's set before After is called.
+	RowsAffected int64
+}
+
+// Interceptor is the interface that wraps the Before and After methods.
+//
+// Before is called before a statement is run. It may return a derived
+// context, e.g. holding a tracing span, that the statement and the following
+// interceptors run with. If it returns an error, the statement isn't run and
+// fails with that error.
+//
+// After is called once the statement has completed, with the context returned
+// by Before. Interceptors are unwound in reverse order, and only the ones whose
+// Before was called have their After called.
+type Interceptor interface {
+	Before(ctx context.Context, info *QueryInfo) (context.Context, error)
+	After(ctx context.Context, info *QueryInfo)
+}
+
+// InterceptorFuncs is an Interceptor made of optional functions.
+type InterceptorFuncs struct {
+	BeforeFunc func(ctx context.Context, info *QueryInfo) (context.Context, error)
+	AfterFunc  func(ctx context.Context, info *QueryInfo)
+}
+
+// Before calls BeforeFunc, if set.
+func (f InterceptorFuncs) Before(ctx context.Context, info *QueryInfo) (context.Context, error) {
+	if f.BeforeFunc == nil {
+		return ctx, nil
+	}
+	return f.BeforeFunc(ctx, info)
+}
+
+// After calls AfterFunc, if set.
+func (f InterceptorFuncs) After(ctx context.Context, info *QueryInfo) {
+	if f.AfterFunc != nil {
+		f.AfterFunc(ctx, info)
+	}
+}
+
+// WrapRunner returns a RunnerContext running statements with runner, through
+// interceptors.
+//
+// Like RunWith, it accepts *sql.DB and *sql.Tx. The Context methods of the
+// result fail with ErrNoContextSupport if runner doesn't have them, and its
+// QueryRow methods fail with ErrRunnerNotQueryRunner if runner can't QueryRow.
+//
+// Ex:
+//
+//	db := WrapRunner(sqlDB, metrics, tracing)
+//	Select("*").From("users").RunWith(db).QueryContext(ctx)
+func WrapRunner(runner BaseRunner, interceptors ...Interceptor) RunnerContext {
+	switch r := runner.(type) {
+	case StdSqlCtx:
+		runner = WrapStdSqlCtx(r)
+	case StdSql:
+		runner = WrapStdSql(r)
+	}
+	return &interceptedRunner{runner: runner, interceptors: interceptors}
+}
+
+type interceptedRunner struct {
+	runner       BaseRunner
+	interceptors []Interceptor
+}
+
+// interception is a statement going through the interceptors of a runner.
+type interception struct {
+	info QueryInfo
+	// ctxs holds the context returned by the Before of each interceptor
+	// that was called.
+	ctxs         []context.Context
+	interceptors []Interceptor
+}
+
+// before calls the Before of each interceptor, returning the context to run
+// the statement with.
+func (r *interceptedRunner) before(ctx context.Context, method, query string, args []interface{}) (*interception, context.Context, error) {
+	ic := &interception{
+		info: QueryInfo{
+			Method:       method,
+			SQL:          query,
+			Args:         args,
+			Start:        time.Now(),
+			RowsAffected: -1,
+		},
+		interceptors: r.interceptors,
+	}
+	for _, interceptor := range r.interceptors {
+		next, err := interceptor.Before(ctx, &ic.info)
+		if err != nil {
+			return ic, ctx, err
+		}
+		if next != nil {
+			ctx = next
+		}
+		ic.ctxs = append(ic.ctxs, ctx)
+	}
+	return ic, ctx, nil
+}
+
+// after records the outcome of the statement and calls the After of the
+// interceptors whose Before was called, in reverse order.
+func (ic *interception) after(err error) {
+	ic.info.Duration = time.Since(ic.info.Start)
+	ic.info.Err = err
+	for i := len(ic.ctxs) - 1; i >= 0; i-- {
+		ic.interceptors[i].After(ic.ctxs[i], &ic.info)
+	}
+}
+
+func (ic *interception) afterExec(res sql.Result, err error) (sql.Result, error) {
+	if err == nil && res != nil {
+		if n, nErr := res.RowsAffected(); nErr == nil {
+			ic.info.RowsAffected = n
+		}
+	}
+	ic.after(err)
+	return res, err
+}
+
+func (ic *interception) afterQuery(rows *sql.Rows, err error) (*sql.Rows, error) {
+	ic.after(err)
+	return rows, err
+}
+
+func (r *interceptedRunner) Exec(query string, args ...interface{}) (sql.Result, error) {
+	ic, _, err := r.before(context.Background(), "Exec", query, args)
+	if err != nil {
+		return ic.afterExec(nil, err)
+	}
+	return ic.afterExec(r.runner.Exec(ic.info.SQL, ic.info.Args...))
+}
+
+func (r *interceptedRunner) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	ic, _, err := r.before(context.Background(), "Query", query, args)
+	if err != nil {
+		return ic.afterQuery(nil, err)
+	}
+	return ic.afterQuery(r.runner.Query(ic.info.SQL, ic.info.Args...))
+}
+
+func (r *interceptedRunner) QueryRow(query string, args ...interface{}) RowScanner {
+	queryRower, ok := r.runner.(QueryRower)
+	if !ok {
+		return &Row{err: ErrRunnerNotQueryRunner}
+	}
+	ic, _, err := r.before(context.Background(), "QueryRow", query, args)
+	if err != nil {
+		return &interceptedRow{ic: ic, err: err}
+	}
+	return &interceptedRow{ic: ic, row: queryRower.QueryRow(ic.info.SQL, ic.info.Args...)}
+}
+
+func (r *interceptedRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	execer, ok := r.runner.(ExecerContext)
+	if !ok {
+		return nil, ErrNoContextSupport
+	}
+	ic, ctx, err := r.before(ctx, "Exec", query, args)
+	if err != nil {
+		return ic.afterExec(nil, err)
+	}
+	return ic.afterExec(execer.ExecContext(ctx, ic.info.SQL, ic.info.Args...))
+}
+
+func (r *interceptedRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	queryer, ok := r.runner.(QueryerContext)
+	if !ok {
+		return nil, ErrNoContextSupport
+	}
+	ic, ctx, err := r.before(ctx, "Query", query, args)
+	if err != nil {
+		return ic.afterQuery(nil, err)
+	}
+	return ic.afterQuery(queryer.QueryContext(ctx, ic.info.SQL, ic.info.Args...))
+}
+
+func (r *interceptedRunner) QueryRowContext(ctx context.Context, query string, args ...interface{}) RowScanner {
+	queryRower, ok := r.runner.(QueryRowerContext)
+	if !ok {
+		if _, ok := r.runner.(QueryRower); !ok {
+			return &Row{err: ErrRunnerNotQueryRunner}
+		}
+		return &Row{err: ErrNoContextSupport}
+	}
+	ic, ctx, err := r.before(ctx, "QueryRow", query, args)
+	if err != nil {
+		return &interceptedRow{ic: ic, err: err}
+	}
+	return &interceptedRow{ic: ic, row: queryRower.QueryRowContext(ctx, ic.info.SQL, ic.info.Args...)}
+}
+
+// interceptedRow calls the After of the interceptors of a QueryRow on Scan,
+// as database/sql only runs the statement then.
+type interceptedRow struct {
+	ic   *interception
+	row  RowScanner
+	err  error
+	done bool
+}
+
+func (r *interceptedRow) Scan(dest ...interface{}) error {
+	err := r.err
+	if err == nil {
+		err = r.row.Scan(dest...)
+	}
+	if !r.done {
+		r.done = true
+		r.ic.after(err)
+	}
+	return err
+}
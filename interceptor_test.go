@@ -0,0 +1,144 @@
+package squirrel2
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ctxKey string
+
+// recordingInterceptor logs its calls to a shared log.
+func recordingInterceptor(name string, log *[]string) Interceptor {
+	return InterceptorFuncs{
+		BeforeFunc: func(ctx context.Context, info *QueryInfo) (context.Context, error) {
+			*log = append(*log, fmt.Sprintf("%s.Before %s %s", name, info.Method, info.SQL))
+			return context.WithValue(ctx, ctxKey(name), name), nil
+		},
+		AfterFunc: func(ctx context.Context, info *QueryInfo) {
+			*log = append(*log, fmt.Sprintf("%s.After %s rows=%d err=%v ctx=%v",
+				name, info.Method, info.RowsAffected, info.Err, ctx.Value(ctxKey(name))))
+		},
+	}
+}
+
+func TestWrapRunnerExec(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	var log []string
+	runner := WrapRunner(db, recordingInterceptor("a", &log), recordingInterceptor("b", &log))
+
+	_, err := Update("t").Set("x", 1).RunWith(runner).ExecContext(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE t SET x = ?", fake.lastSql)
+	assert.Equal(t, []string{
+		"a.Before Exec UPDATE t SET x = ?",
+		"b.Before Exec UPDATE t SET x = ?",
+		"b.After Exec rows=1 err=<nil> ctx=b",
+		"a.After Exec rows=1 err=<nil> ctx=a",
+	}, log)
+}
+
+func TestWrapRunnerRewrite(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{columns: []string{"x"}})
+	var seen []interface{}
+	runner := WrapRunner(db, InterceptorFuncs{
+		BeforeFunc: func(ctx context.Context, info *QueryInfo) (context.Context, error) {
+			info.SQL = "/* app */ " + info.SQL
+			return ctx, nil
+		},
+		AfterFunc: func(ctx context.Context, info *QueryInfo) {
+			seen = info.Args
+		},
+	})
+
+	rows, err := Select("x").From("t").Where(Eq{"y": 2}).RunWith(runner).Query()
+	assert.NoError(t, err)
+	rows.Close()
+	assert.Equal(t, "/* app */ SELECT x FROM t WHERE y = ?", fake.lastSql)
+	assert.Equal(t, []driver.NamedValue{{Ordinal: 1, Value: int64(2)}}, fake.lastArgs)
+	assert.Equal(t, []interface{}{2}, seen)
+}
+
+func TestWrapRunnerFaultInjection(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	injected := errors.New("injected")
+	var log []string
+	runner := WrapRunner(db,
+		recordingInterceptor("a", &log),
+		InterceptorFuncs{BeforeFunc: func(ctx context.Context, info *QueryInfo) (context.Context, error) {
+			return nil, injected
+		}},
+		recordingInterceptor("c", &log),
+	)
+
+	_, err := Delete("t").RunWith(runner).ExecContext(context.Background())
+	assert.Equal(t, injected, err)
+	assert.Empty(t, fake.statements())
+	assert.Equal(t, []string{
+		"a.Before Exec DELETE FROM t",
+		"a.After Exec rows=-1 err=injected ctx=a",
+	}, log)
+
+	log = nil
+	var x int
+	err = Select("x").From("t").RunWith(runner).ScanContext(context.Background(), &x)
+	assert.Equal(t, injected, err)
+	assert.Equal(t, []string{
+		"a.Before QueryRow SELECT x FROM t",
+		"a.After QueryRow rows=-1 err=injected ctx=a",
+	}, log)
+}
+
+func TestWrapRunnerQueryRow(t *testing.T) {
+	db, _ := newFakeDB(fakeResult{columns: []string{"x"}, rows: [][]driver.Value{{int64(3)}}})
+	var log []string
+	runner := WrapRunner(db, recordingInterceptor("a", &log))
+
+	row := Select("x").From("t").RunWith(runner).QueryRowContext(context.Background())
+	assert.Equal(t, []string{"a.Before QueryRow SELECT x FROM t"}, log)
+
+	var x int
+	assert.NoError(t, row.Scan(&x))
+	assert.Equal(t, 3, x)
+	assert.Equal(t, "a.After QueryRow rows=-1 err=<nil> ctx=a", log[1])
+
+	db, _ = newFakeDB(fakeResult{columns: []string{"x"}})
+	log = nil
+	runner = WrapRunner(db, recordingInterceptor("a", &log))
+	err := Select("x").From("t").RunWith(runner).Scan(&x)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, []string{
+		"a.Before QueryRow SELECT x FROM t",
+		"a.After QueryRow rows=-1 err=sql: no rows in result set ctx=a",
+	}, log)
+}
+
+func TestWrapRunnerNoContext(t *testing.T) {
+	stub := &DBStub{}
+	var log []string
+	// Hide the Context methods of DBStub.
+	runner := WrapRunner(struct{ Runner }{stub}, recordingInterceptor("a", &log))
+
+	_, err := runner.Exec("DELETE FROM t WHERE id = ?", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "DELETE FROM t WHERE id = ?", stub.LastExecSql)
+	assert.Equal(t, []interface{}{1}, stub.LastExecArgs)
+	assert.Equal(t, []string{
+		"a.Before Exec DELETE FROM t WHERE id = ?",
+		"a.After Exec rows=-1 err=<nil> ctx=a",
+	}, log)
+
+	_, err = runner.ExecContext(context.Background(), "DELETE FROM t")
+	assert.Equal(t, ErrNoContextSupport, err)
+	_, err = runner.QueryContext(context.Background(), "SELECT 1")
+	assert.Equal(t, ErrNoContextSupport, err)
+	assert.Equal(t, ErrNoContextSupport, runner.QueryRowContext(context.Background(), "SELECT 1").Scan())
+
+	runner = WrapRunner(struct{ BaseRunner }{stub})
+	assert.Equal(t, ErrRunnerNotQueryRunner, runner.QueryRow("SELECT 1").Scan())
+}
@@ -0,0 +1,267 @@
+package squirrel2
+
+import (
+	"strings"
+)
+
+// sqlTokenKind identifies the tokens of a SQL statement that placeholder
+// handling cares about.
+type sqlTokenKind int
+
+const (
+	// sqlText is any run of SQL that isn't one of the other kinds.
+	sqlText sqlTokenKind = iota
+	// sqlQuoted is a string literal, quoted identifier or comment. Its text
+	// must be copied verbatim.
+	sqlQuoted
+	// sqlPlaceholder is a "?" placeholder.
+	sqlPlaceholder
+	// sqlEscapedPlaceholder is a "??", standing for a literal "?".
+	sqlEscapedPlaceholder
+	// sqlPositional is an already numbered placeholder, e.g. "$1". These are
+	// only reported if sqlLexer.positional is set.
+	sqlPositional
+	// sqlNamed is a named parameter marker, e.g. ":id" or "@id". These are
+	// only reported if sqlLexer.named is set.
+	sqlNamed
+)
+
+// sqlToken is a span of a SQL statement.
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+	// pos is the byte offset of text in the statement.
+	pos int
+	// index is the number of a sqlPositional placeholder.
+	index int
+	// name is the name of a sqlNamed marker, without its ":" or "@".
+	name string
+}
+
+// sqlLexer splits SQL statements into tokens. It's deliberately small: it
+// knows just enough of the lexical structure of SQL to find the placeholders
+// that aren't inside string literals, quoted identifiers, dollar-quoted bodies
+// or comments.
+type sqlLexer struct {
+	// jsonbOperators reports the Postgres ?| and ?& operators as text instead
+	// of a placeholder followed by an operator.
+	jsonbOperators bool
+	// backslashEscapes makes backslashes escape the next character in all
+	// string literals, as they do in MySQL, e.g. 'it\'s'. Otherwise they only
+	// do in Postgres escape strings, e.g. E'it\'s'.
+	backslashEscapes bool
+	// positional, if not empty, is the prefix of numbered placeholders (e.g.
+	// "$") to report as sqlPositional tokens.
+	positional string
+	// named reports :name and @name markers as sqlNamed tokens.
+	named bool
+}
+
+// lexerFor returns the sqlLexer matching the dialect of f.
+func lexerFor(f PlaceholderFormat) sqlLexer {
+	switch f.(type) {
+	case dollarJSONBFormat:
+		return sqlLexer{jsonbOperators: true}
+	case mysqlQuestionFormat:
+		return sqlLexer{backslashEscapes: true}
+	}
+	return sqlLexer{}
+}
+
+// scan calls fn with each token of sql, in order. Concatenating the text of
+// every token gives back sql. Scanning stops at the first error returned by fn.
+func (l sqlLexer) scan(sql string, fn func(tok sqlToken) error) error {
+	textStart := 0
+	flush := func(end int) error {
+		if end > textStart {
+			return fn(sqlToken{kind: sqlText, text: sql[textStart:end], pos: textStart})
+		}
+		return nil
+	}
+	emit := func(kind sqlTokenKind, start, end, index int) error {
+		if err := flush(start); err != nil {
+			return err
+		}
+		textStart = end
+		tok := sqlToken{kind: kind, text: sql[start:end], pos: start, index: index}
+		if kind == sqlNamed {
+			tok.name = tok.text[1:]
+		}
+		return fn(tok)
+	}
+
+	for i := 0; i < len(sql); {
+		var (
+			kind = sqlQuoted
+			end  = -1
+			idx  int
+		)
+		switch c := sql[i]; {
+		case c == '\'':
+			end = scanQuoted(sql, i, '\'', l.backslashEscapes || isEscapeStringPrefix(sql, i))
+		case c == '"':
+			// MySQL reads "..." as a string literal, unless ANSI_QUOTES is set.
+			end = scanQuoted(sql, i, c, l.backslashEscapes)
+		case c == '`':
+			end = scanQuoted(sql, i, c, false)
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			end = scanLineComment(sql, i)
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end = scanBlockComment(sql, i)
+		case c == '$' && (i == 0 || !isIdentByte(sql[i-1])):
+			end = scanDollarQuoted(sql, i)
+		case c == '?':
+			switch next := byteAt(sql, i+1); {
+			case next == '?':
+				kind, end = sqlEscapedPlaceholder, i+2
+			case l.jsonbOperators && (next == '|' || next == '&') && byteAt(sql, i+2) != next:
+				// ?| and ?& are operators, while ?|| is a placeholder
+				// followed by the concatenation operator.
+				i += 2
+				continue
+			default:
+				kind, end = sqlPlaceholder, i+1
+			}
+		case l.named && (c == ':' || c == '@'):
+			// Skip casts (::int), MySQL system variables (@@x) and slices
+			// (arr[1:n]).
+			if i == 0 || (sql[i-1] != c && !isIdentByte(sql[i-1])) {
+				if size := scanName(sql[i+1:]); size > 0 {
+					kind, end = sqlNamed, i+1+size
+				}
+			}
+		}
+		if end < 0 && l.positional != "" && strings.HasPrefix(sql[i:], l.positional) &&
+			(i == 0 || !isIdentByte(sql[i-1])) {
+			if n, size := scanDigits(sql[i+len(l.positional):]); size > 0 {
+				kind, end, idx = sqlPositional, i+len(l.positional)+size, n
+			}
+		}
+		if end < 0 {
+			i++
+			continue
+		}
+		if err := emit(kind, i, end, idx); err != nil {
+			return err
+		}
+		i = end
+	}
+	return flush(len(sql))
+}
+
+func byteAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return 0
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// isEscapeStringPrefix reports whether the quote at sql[i] opens a Postgres
+// escape string (E'...'), in which backslashes escape the next character.
+func isEscapeStringPrefix(sql string, i int) bool {
+	if i == 0 || (sql[i-1] != 'E' && sql[i-1] != 'e') {
+		return false
+	}
+	return i == 1 || !isIdentByte(sql[i-2])
+}
+
+// scanQuoted returns the end of the quoted span starting at sql[start], where
+// a doubled quote character stands for itself. Unterminated spans run to the
+// end of sql.
+func scanQuoted(sql string, start int, quote byte, backslashEscapes bool) int {
+	for i := start + 1; i < len(sql); i++ {
+		switch sql[i] {
+		case '\\':
+			if backslashEscapes {
+				i++
+			}
+		case quote:
+			if byteAt(sql, i+1) != quote {
+				return i + 1
+			}
+			i++
+		}
+	}
+	return len(sql)
+}
+
+func scanLineComment(sql string, start int) int {
+	if end := strings.IndexByte(sql[start:], '\n'); end >= 0 {
+		return start + end + 1
+	}
+	return len(sql)
+}
+
+// scanBlockComment returns the end of the /* ... */ comment starting at
+// sql[start]. Comments nest, as they do in Postgres.
+func scanBlockComment(sql string, start int) int {
+	depth := 0
+	for i := start; i < len(sql)-1; i++ {
+		switch sql[i : i+2] {
+		case "/*":
+			depth++
+			i++
+		case "*/":
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(sql)
+}
+
+// scanDollarQuoted returns the end of the Postgres dollar-quoted body (e.g.
+// $$ ... $$ or $fn$ ... $fn$) starting at sql[start], or -1 if there isn't one.
+func scanDollarQuoted(sql string, start int) int {
+	i := start + 1
+	if i < len(sql) && ('0' <= sql[i] && sql[i] <= '9') {
+		// $1 is a positional parameter, not a tag.
+		return -1
+	}
+	for i < len(sql) && sql[i] != '$' {
+		if !isIdentByte(sql[i]) {
+			return -1
+		}
+		i++
+	}
+	if i >= len(sql) {
+		return -1
+	}
+	tag := sql[start : i+1]
+	if end := strings.Index(sql[i+1:], tag); end >= 0 {
+		return i + 1 + end + len(tag)
+	}
+	return len(sql)
+}
+
+// scanName returns the length of the parameter name at the start of s, or 0
+// if s doesn't start with one.
+func scanName(s string) int {
+	size := 0
+	for size < len(s) {
+		c := s[size]
+		if c != '_' && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') &&
+			(size == 0 || !('0' <= c && c <= '9')) {
+			break
+		}
+		size++
+	}
+	return size
+}
+
+// scanDigits parses the decimal number at the start of s, returning it and
+// the number of bytes it takes.
+func scanDigits(s string) (n, size int) {
+	for size < len(s) && '0' <= s[size] && s[size] <= '9' {
+		n = n*10 + int(s[size]-'0')
+		size++
+	}
+	return
+}
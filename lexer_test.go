@@ -0,0 +1,43 @@
+package squirrel2
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSqlLexerScan(t *testing.T) {
+	sql := "SELECT a::int, 'x?' FROM t -- c?\nWHERE b = ? AND c = $2 AND d ?? e"
+
+	var (
+		rebuilt strings.Builder
+		kinds   []sqlTokenKind
+	)
+	err := sqlLexer{positional: "$"}.scan(sql, func(tok sqlToken) error {
+		rebuilt.WriteString(tok.text)
+		if tok.kind != sqlText {
+			kinds = append(kinds, tok.kind)
+		}
+		if tok.kind == sqlPositional {
+			assert.Equal(t, 2, tok.index)
+		}
+		assert.Equal(t, tok.text, sql[tok.pos:tok.pos+len(tok.text)])
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, sql, rebuilt.String())
+	assert.Equal(t, []sqlTokenKind{
+		sqlQuoted, sqlQuoted, sqlPlaceholder, sqlPositional, sqlEscapedPlaceholder,
+	}, kinds)
+}
+
+func TestSqlLexerUnterminated(t *testing.T) {
+	var kinds []sqlTokenKind
+	err := sqlLexer{}.scan("a = ? AND b = 'oops ?", func(tok sqlToken) error {
+		kinds = append(kinds, tok.kind)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []sqlTokenKind{sqlText, sqlPlaceholder, sqlText, sqlQuoted}, kinds)
+}
@@ -0,0 +1,311 @@
+package squirrel2
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DefaultRedactedColumns is used by LogOptions when RedactColumns is nil.
+var DefaultRedactedColumns = []string{"password", "passwd", "secret", "token", "api_key", "apikey"}
+
+// redactedValue replaces the args bound to redacted columns in logs.
+const redactedValue = "[REDACTED]"
+
+// LogOptions configures the runners returned by WrapRunnerWithLogger.
+type LogOptions struct {
+	// Level is the level statements are logged at.
+	Level slog.Level
+	// SlowThreshold, if positive, logs the statements that take at least
+	// that long at slog.LevelWarn instead. Failed statements are always
+	// logged at slog.LevelError.
+	SlowThreshold time.Duration
+	// RedactColumns lists the column names whose args are masked in logs.
+	// A column matches if its name contains one of them, ignoring case, so
+	// "password" also masks "password_hash". Nil means DefaultRedactedColumns.
+	//
+	// Args that can't be tied to a column, e.g. in "lower(email) = ?" or
+	// "password = crypt(?, gen_salt('bf'))", are masked too.
+	RedactColumns []string
+}
+
+// WrapRunnerWithLogger returns a RunnerContext logging every statement run
+// with runner to logger: its SQL, args, duration, error and the location of
+// the code that ran it, and the rows affected by an Exec. The rows returned
+// by a Query aren't counted, as they are read after it's logged. See
+// WrapRunner.
+//
+// Ex:
+//
+//	db := WrapRunnerWithLogger(sqlDB, slog.Default(), LogOptions{SlowThreshold: time.Second})
+func WrapRunnerWithLogger(runner BaseRunner, logger *slog.Logger, opts LogOptions) RunnerContext {
+	return WrapRunner(runner, NewLogInterceptor(logger, opts))
+}
+
+// NewLogInterceptor returns the Interceptor of WrapRunnerWithLogger, e.g. to
+// combine it with others.
+func NewLogInterceptor(logger *slog.Logger, opts LogOptions) Interceptor {
+	redact := opts.RedactColumns
+	if redact == nil {
+		redact = DefaultRedactedColumns
+	}
+	lower := make([]string, len(redact))
+	for i, column := range redact {
+		lower[i] = strings.ToLower(column)
+	}
+	return &logInterceptor{logger: logger, opts: opts, redact: lower}
+}
+
+type logInterceptor struct {
+	logger *slog.Logger
+	opts   LogOptions
+	redact []string
+}
+
+type callerKey struct{}
+
+func (l *logInterceptor) Before(ctx context.Context, info *QueryInfo) (context.Context, error) {
+	// After may run from the Scan of a QueryRow, far from the caller.
+	return context.WithValue(ctx, callerKey{}, callerPC()), nil
+}
+
+func (l *logInterceptor) After(ctx context.Context, info *QueryInfo) {
+	level, msg := l.opts.Level, "query"
+	switch {
+	case info.Err != nil && !errors.Is(info.Err, sql.ErrNoRows):
+		level, msg = slog.LevelError, "query failed"
+	case l.opts.SlowThreshold > 0 && info.Duration >= l.opts.SlowThreshold:
+		level, msg = slog.LevelWarn, "slow query"
+	}
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+
+	pc, _ := ctx.Value(callerKey{}).(uintptr)
+	r := slog.NewRecord(time.Now(), level, msg, pc)
+	r.AddAttrs(
+		slog.String("method", info.Method),
+		slog.String("sql", info.SQL),
+		slog.Any("args", redactArgs(info.SQL, info.Args, l.redact)),
+		slog.Duration("duration", info.Duration),
+	)
+	if info.RowsAffected >= 0 {
+		r.AddAttrs(slog.Int64("rows_affected", info.RowsAffected))
+	}
+	if info.Err != nil {
+		r.AddAttrs(slog.Any("error", info.Err))
+	}
+	_ = l.logger.Handler().Handle(ctx, r)
+}
+
+// packageDir is the directory of the sources of this package.
+var packageDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// callerPC returns the program counter of the first caller outside of this
+// package.
+func callerPC() uintptr {
+	var pcs [32]uintptr
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if filepath.Dir(frame.File) != packageDir || strings.HasSuffix(frame.File, "_test.go") {
+			return frame.PC
+		}
+		if !more {
+			return 0
+		}
+	}
+}
+
+// positionalPrefixes are the prefixes of the numbered placeholders of the
+// PlaceholderFormats, tried in turn by redactArgs.
+var positionalPrefixes = []string{"$", "@p", ":"}
+
+// limitRegexp matches the LIMIT or OFFSET keyword before a placeholder, whose
+// arg is a row count rather than a column value.
+var limitRegexp = regexp.MustCompile(`(?i)\b(LIMIT|OFFSET)\s*$`)
+
+// comparedColumnRegexp matches the column compared to, or assigned, the
+// placeholder that follows the text it's matched against.
+var comparedColumnRegexp = regexp.MustCompile(
+	`(?i)([\w.]+|"[^"]*"|` + "`[^`]*`" + `|\[[^\]]*\])\s*(=|<>|!=|<=|>=|<|>|\bI?LIKE|\b(?:NOT\s+)?IN\s*\()\s*$`)
+
+// listSeparatorRegexp matches the text between the placeholders of an IN list.
+var listSeparatorRegexp = regexp.MustCompile(`^\s*,\s*$`)
+
+// insertColumnsRegexp matches the column list of an INSERT statement, up to
+// its VALUES keyword.
+var insertColumnsRegexp = regexp.MustCompile(`(?is)^\s*(?:INSERT|REPLACE)\b[^(]*\(([^)]*)\)\s*VALUES\b`)
+
+// redactArgs returns a copy of args where the values bound to redacted
+// columns are masked. It finds the column of each placeholder of query
+// lexically: the column a placeholder is compared to (e.g. "password = ?" or
+// "token IN (?, ?)"), or the column matching its position in the VALUES of an
+// INSERT. It fails closed: an arg is only kept if every placeholder bound to
+// it is tied to a column that isn't redacted, or follows LIMIT or OFFSET.
+// Named args are also masked if their name is redacted.
+func redactArgs(query string, args []interface{}, redact []string) []interface{} {
+	if len(args) == 0 || len(redact) == 0 {
+		return args
+	}
+	isRedacted := func(column string) bool {
+		column = strings.ToLower(strings.Trim(column[strings.LastIndexByte(column, '.')+1:], "\"`[]"))
+		for _, r := range redact {
+			if r != "" && strings.Contains(column, r) {
+				return true
+			}
+		}
+		return false
+	}
+
+	namedIndex := make(map[string]int)
+	for i, arg := range args {
+		if na, ok := arg.(sql.NamedArg); ok {
+			namedIndex[na.Name] = i
+		}
+	}
+	lexer := positionalLexer(query)
+	lexer.named = len(namedIndex) > 0
+
+	// keep and mask record whether an arg is bound to a column that isn't
+	// redacted, and whether it's bound anywhere else.
+	keep := make([]bool, len(args))
+	mask := make([]bool, len(args))
+	var (
+		// text is the SQL since the previous placeholder.
+		text strings.Builder
+		next int
+		// listColumn is the column of the IN list being scanned, if any.
+		listColumn string
+		// insertColumns, depth and valueIndex locate the placeholders in
+		// the VALUES of an INSERT.
+		insertColumns []string
+		depth         int
+		valueIndex    int
+	)
+	countValues := func(s string) {
+		for _, c := range s {
+			switch c {
+			case '(':
+				depth++
+			case ')':
+				depth--
+				if depth == 0 {
+					valueIndex = 0
+				}
+			case ',':
+				if depth == 1 {
+					valueIndex++
+				}
+			}
+		}
+	}
+
+	_ = lexer.scan(query, func(tok sqlToken) error {
+		argIndex := tok.index - 1
+		switch tok.kind {
+		case sqlPlaceholder:
+			argIndex = next
+			next++
+		case sqlPositional:
+		case sqlNamed:
+			if i, ok := namedIndex[tok.name]; ok {
+				argIndex = i
+				break
+			}
+			// Numbered placeholders such as @p1 are read as names too.
+			digits := strings.TrimPrefix(tok.text, lexer.positional)
+			n, size := scanDigits(digits)
+			if lexer.positional == "" || len(digits) == len(tok.text) || size == 0 || size != len(digits) {
+				text.WriteString(tok.text)
+				return nil
+			}
+			argIndex = n - 1
+		default:
+			text.WriteString(tok.text)
+			if insertColumns == nil {
+				if loc := insertColumnsRegexp.FindStringSubmatchIndex(text.String()); loc != nil {
+					s := text.String()
+					insertColumns = strings.Split(s[loc[2]:loc[3]], ",")
+					countValues(s[loc[1]:])
+				}
+			} else if tok.kind == sqlText {
+				countValues(tok.text)
+			}
+			return nil
+		}
+
+		before := text.String()
+		text.Reset()
+		column := ""
+		if m := limitRegexp.FindStringSubmatch(before); m != nil {
+			column = m[1]
+			listColumn = ""
+		} else if m := comparedColumnRegexp.FindStringSubmatch(before); m != nil {
+			column = m[1]
+			listColumn = ""
+			if strings.HasSuffix(m[2], "(") {
+				listColumn = column
+			}
+		} else if listColumn != "" && listSeparatorRegexp.MatchString(before) {
+			column = listColumn
+		} else {
+			listColumn = ""
+			if insertColumns != nil && depth >= 1 && valueIndex < len(insertColumns) {
+				column = strings.TrimSpace(insertColumns[valueIndex])
+			}
+		}
+		if argIndex >= 0 && argIndex < len(args) {
+			if column == "" || isRedacted(column) {
+				mask[argIndex] = true
+			} else {
+				keep[argIndex] = true
+			}
+		}
+		return nil
+	})
+
+	redacted := make([]interface{}, len(args))
+	for i, arg := range args {
+		na, isNamed := arg.(sql.NamedArg)
+		switch {
+		case keep[i] && !mask[i] && !(isNamed && isRedacted(na.Name)):
+			redacted[i] = arg
+		case isNamed:
+			redacted[i] = sql.Named(na.Name, redactedValue)
+		default:
+			redacted[i] = redactedValue
+		}
+	}
+	return redacted
+}
+
+// positionalLexer returns a sqlLexer reporting the numbered placeholders of
+// query, whichever PlaceholderFormat produced them.
+func positionalLexer(query string) sqlLexer {
+	for _, prefix := range positionalPrefixes {
+		if !strings.Contains(query, prefix) {
+			continue
+		}
+		l := sqlLexer{positional: prefix}
+		found := false
+		_ = l.scan(query, func(tok sqlToken) error {
+			found = found || tok.kind == sqlPositional
+			return nil
+		})
+		if found {
+			return l
+		}
+	}
+	return sqlLexer{}
+}
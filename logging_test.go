@@ -0,0 +1,174 @@
+package squirrel2
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// logEntries decodes the records written by a slog.JSONHandler.
+func logEntries(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var entries []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var entry map[string]interface{}
+		assert.NoError(t, dec.Decode(&entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestWrapRunnerWithLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{AddSource: true, Level: slog.LevelDebug}))
+	db, _ := newFakeDB(fakeResult{})
+	runner := WrapRunnerWithLogger(db, logger, LogOptions{Level: slog.LevelDebug})
+
+	_, err := Update("users").Set("name", "ann").Set("password", "hunter2").
+		Where(Eq{"id": 1}).PlaceholderFormat(Dollar).RunWith(runner).ExecContext(context.Background())
+	assert.NoError(t, err)
+
+	entries := logEntries(t, buf)
+	if assert.Len(t, entries, 1) {
+		entry := entries[0]
+		assert.Equal(t, "DEBUG", entry["level"])
+		assert.Equal(t, "query", entry["msg"])
+		assert.Equal(t, "Exec", entry["method"])
+		assert.Equal(t, "UPDATE users SET name = $1, password = $2 WHERE id = $3", entry["sql"])
+		assert.Equal(t, []interface{}{"ann", "[REDACTED]", 1.0}, entry["args"])
+		assert.Equal(t, 1.0, entry["rows_affected"])
+		assert.Contains(t, entry, "duration")
+
+		source := entry["source"].(map[string]interface{})
+		assert.Equal(t, "logging_test.go", filepath.Base(source["file"].(string)))
+		assert.Contains(t, source["function"], "TestWrapRunnerWithLogger")
+	}
+
+	// The rows of a Query aren't counted.
+	buf.Reset()
+	rows, err := Select("id").From("users").RunWith(runner).QueryContext(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, rows.Close())
+	entries = logEntries(t, buf)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "Query", entries[0]["method"])
+		assert.NotContains(t, entries[0], "rows_affected")
+	}
+}
+
+func TestWrapRunnerWithLoggerLevels(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+	db, fake := newFakeDB(fakeResult{columns: []string{"x"}})
+
+	// Statements under the threshold are logged at Debug, which is disabled.
+	runner := WrapRunnerWithLogger(db, logger, LogOptions{Level: slog.LevelDebug, SlowThreshold: time.Hour})
+	_, err := runner.Exec("DELETE FROM t")
+	assert.NoError(t, err)
+	assert.Empty(t, logEntries(t, buf))
+
+	runner = WrapRunnerWithLogger(db, logger, LogOptions{Level: slog.LevelDebug, SlowThreshold: time.Nanosecond})
+	_, err = runner.Exec("DELETE FROM t")
+	assert.NoError(t, err)
+
+	// sql.ErrNoRows isn't a failure.
+	var x int
+	assert.Equal(t, sql.ErrNoRows, Select("x").From("t").RunWith(runner).Scan(&x))
+
+	fake.execErr = func(string) error { return errors.New("boom") }
+	_, err = runner.Exec("DELETE FROM t")
+	assert.Error(t, err)
+
+	entries := logEntries(t, buf)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, "WARN", entries[0]["level"])
+		assert.Equal(t, "slow query", entries[0]["msg"])
+		assert.Equal(t, "WARN", entries[1]["level"])
+		assert.Equal(t, "QueryRow", entries[1]["method"])
+		assert.Equal(t, "sql: no rows in result set", entries[1]["error"])
+		assert.Equal(t, "ERROR", entries[2]["level"])
+		assert.Equal(t, "query failed", entries[2]["msg"])
+		assert.Equal(t, "boom", entries[2]["error"])
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	redact := []string{"password", "token"}
+	tests := []struct {
+		sql      string
+		args     []interface{}
+		expected []interface{}
+	}{
+		{
+			"SELECT * FROM users WHERE name = ? AND u.password_hash = ?",
+			[]interface{}{"ann", "x"},
+			[]interface{}{"ann", redactedValue},
+		},
+		{
+			`UPDATE users SET "Password" = $2, name = $1`,
+			[]interface{}{"ann", "x"},
+			[]interface{}{"ann", redactedValue},
+		},
+		{
+			"DELETE FROM sessions WHERE token IN (@p1, @p2) AND id > @p3",
+			[]interface{}{"a", "b", 3},
+			[]interface{}{redactedValue, redactedValue, 3},
+		},
+		{
+			"INSERT INTO users (name, password, age) VALUES (?, ?, ?), (?, lower(?), ?)",
+			[]interface{}{"ann", "x", 30, "bob", "y", 40},
+			[]interface{}{"ann", redactedValue, 30, "bob", redactedValue, 40},
+		},
+		{
+			"SELECT * FROM t WHERE note = 'password = ?' AND x = :1",
+			[]interface{}{"a"},
+			[]interface{}{"a"},
+		},
+		{
+			"SELECT * FROM t WHERE api_token = @token",
+			[]interface{}{sql.Named("token", "a")},
+			[]interface{}{sql.Named("token", redactedValue)},
+		},
+		// Args that can't be tied to a column are masked.
+		{
+			"UPDATE users SET password = crypt(?, gen_salt('bf')) WHERE id = ?",
+			[]interface{}{"x", 1},
+			[]interface{}{redactedValue, 1},
+		},
+		{
+			"SELECT * FROM sessions WHERE lower(token) = $1 AND id = $2 LIMIT $3 OFFSET $4",
+			[]interface{}{"a", 1, 10, 20},
+			[]interface{}{redactedValue, 1, 10, 20},
+		},
+		{
+			"SELECT * FROM t WHERE a = $1 OR b BETWEEN $1 AND $2",
+			[]interface{}{1, 2},
+			[]interface{}{redactedValue, redactedValue},
+		},
+		{
+			"INSERT INTO t VALUES (?, ?)",
+			[]interface{}{1, "x"},
+			[]interface{}{redactedValue, redactedValue},
+		},
+		{
+			"SELECT * FROM users WHERE password = @secret AND id = @p1 AND name = @name",
+			[]interface{}{1, sql.Named("secret", "x"), sql.Named("name", "ann")},
+			[]interface{}{1, sql.Named("secret", redactedValue), sql.Named("name", "ann")},
+		},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, redactArgs(test.sql, test.args, redact), test.sql)
+	}
+
+	args := []interface{}{"x"}
+	redactArgs("UPDATE t SET password = ?", args, redact)
+	assert.Equal(t, []interface{}{"x"}, args)
+}
@@ -0,0 +1,136 @@
+package squirrel2
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"reflect"
+)
+
+// namedArg is the raw form of an arg bound to a named parameter marker. The
+// marker itself is rendered as a plain "?" placeholder, which lets the
+// PlaceholderFormat of the outermost statement decide how to number it.
+type namedArg struct {
+	name  string
+	value interface{}
+}
+
+// namedBindings returns the values of the named parameters of an Expr, if its
+// args are a single map[string]interface{} or only sql.NamedArgs, and its SQL
+// has :name or @name markers but no "?" placeholders. Other Exprs bind their
+// args positionally, e.g. a map holding a JSON value, or sql.NamedArgs passed
+// through to the driver.
+func namedBindings(sqlStr string, args []interface{}) (map[string]interface{}, bool) {
+	if len(args) == 0 {
+		return nil, false
+	}
+	var bindings map[string]interface{}
+	if m, ok := args[0].(map[string]interface{}); ok && len(args) == 1 {
+		bindings = m
+	} else {
+		bindings = make(map[string]interface{}, len(args))
+		for _, arg := range args {
+			na, ok := arg.(sql.NamedArg)
+			if !ok {
+				return nil, false
+			}
+			bindings[na.Name] = na.Value
+		}
+	}
+
+	hasNamed, hasPlaceholders := false, false
+	_ = sqlLexer{named: true}.scan(sqlStr, func(tok sqlToken) error {
+		hasNamed = hasNamed || tok.kind == sqlNamed
+		hasPlaceholders = hasPlaceholders || tok.kind == sqlPlaceholder
+		return nil
+	})
+	if !hasNamed || hasPlaceholders {
+		return nil, false
+	}
+	return bindings, true
+}
+
+// bindNamedToSql replaces each :name or @name marker of sqlStr with a "?"
+// placeholder bound to the namedArg holding its value. sqlStr must not have
+// "?" placeholders; see namedBindings.
+func bindNamedToSql(sqlStr string, bindings map[string]interface{}) (string, []interface{}, error) {
+	buf := &bytes.Buffer{}
+	var args []interface{}
+	err := sqlLexer{named: true}.scan(sqlStr, func(tok sqlToken) error {
+		switch tok.kind {
+		case sqlNamed:
+			value, ok := bindings[tok.name]
+			if !ok {
+				return fmt.Errorf("missing value for named arg %q", tok.name)
+			}
+			if s, ok := value.(Sqlizer); ok {
+				vsql, vargs, err := nestedToSql(s)
+				if err != nil {
+					return err
+				}
+				buf.WriteString(vsql)
+				args = append(args, vargs...)
+				return nil
+			}
+			buf.WriteString("?")
+			args = append(args, namedArg{name: tok.name, value: value})
+		default:
+			buf.WriteString(tok.text)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return buf.String(), args, nil
+}
+
+// resolveNamedArgs replaces each namedArg in args by its value, binding every
+// occurrence of a named parameter to its own "?" placeholder. It takes the
+// results of a toSqlRaw so that ToSql methods can wrap it directly.
+func resolveNamedArgs(sql string, args []interface{}, err error) (string, []interface{}, error) {
+	if err != nil {
+		return "", nil, err
+	}
+	copied := false
+	for i, arg := range args {
+		if na, ok := arg.(namedArg); ok {
+			if !copied {
+				// args may be shared with the Sqlizer that produced them.
+				args = append([]interface{}(nil), args...)
+				copied = true
+			}
+			args[i] = na.value
+		}
+	}
+	return sql, args, nil
+}
+
+// namedNumbers tracks the numbers given to named args while rewriting the
+// placeholders of a statement.
+type namedNumbers struct {
+	numbers map[string]int
+	values  map[string]interface{}
+}
+
+// lookup returns the number given to na, or 0 if it hasn't got one yet. It
+// fails if na's name was bound to a different value before.
+func (n *namedNumbers) lookup(na namedArg) (int, error) {
+	num, ok := n.numbers[na.name]
+	if !ok {
+		return 0, nil
+	}
+	if !reflect.DeepEqual(n.values[na.name], na.value) {
+		return 0, fmt.Errorf("named arg %q is bound to different values", na.name)
+	}
+	return num, nil
+}
+
+func (n *namedNumbers) add(na namedArg, num int) {
+	if n.numbers == nil {
+		n.numbers = make(map[string]int)
+		n.values = make(map[string]interface{})
+	}
+	n.numbers[na.name] = num
+	n.values[na.name] = na.value
+}
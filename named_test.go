@@ -0,0 +1,103 @@
+package squirrel2
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExprNamedToSql(t *testing.T) {
+	b := Expr("x::int = :v OR y = @v OR z = :w", map[string]interface{}{"v": 1, "w": 2})
+	query, args, err := b.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "x::int = ? OR y = ? OR z = ?", query)
+	assert.Equal(t, []interface{}{1, 1, 2}, args)
+
+	b = Expr("a = @a AND b = @b", sql.Named("a", "x"), sql.Named("b", "y"))
+	query, args, err = b.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "a = ? AND b = ?", query)
+	assert.Equal(t, []interface{}{"x", "y"}, args)
+}
+
+func TestExprPositionalMapAndNamedArgs(t *testing.T) {
+	// A map is a JSON value for drivers like pgx when the SQL has no markers.
+	doc := map[string]interface{}{"a": 1}
+	query, args, err := Expr("data @> ?", doc).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "data @> ?", query)
+	assert.Equal(t, []interface{}{doc}, args)
+
+	// sql.NamedArgs for ? placeholders are passed through to the driver.
+	query, args, err = Expr("x = ?", sql.Named("p", 1)).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "x = ?", query)
+	assert.Equal(t, []interface{}{sql.Named("p", 1)}, args)
+
+	// With ? placeholders, markers are left as they are.
+	query, args, err = Expr("a = :v AND b = ?", doc).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "a = :v AND b = ?", query)
+	assert.Equal(t, []interface{}{doc}, args)
+
+	query, args, err = Select("*").From("t").Where(Expr("data @> ?", doc)).PlaceholderFormat(Dollar).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE data @> $1", query)
+	assert.Equal(t, []interface{}{doc}, args)
+}
+
+func TestExprNamedErrors(t *testing.T) {
+	_, _, err := Expr("a = :missing", map[string]interface{}{"v": 1}).ToSql()
+	assert.EqualError(t, err, `missing value for named arg "missing"`)
+
+	_, _, err = Select("*").From("t").
+		Where(Expr("a = :v", map[string]interface{}{"v": 1})).
+		Where(Expr("b = :v", map[string]interface{}{"v": 2})).
+		PlaceholderFormat(Dollar).
+		ToSql()
+	assert.EqualError(t, err, `named arg "v" is bound to different values`)
+}
+
+func TestNamedPlaceholderFormats(t *testing.T) {
+	b := Select("*").From("t").
+		Where(Eq{"a": 1}).
+		Where(Or{
+			Expr("created_at > :since", map[string]interface{}{"since": 10}),
+			Expr("updated_at > :since", map[string]interface{}{"since": 10}),
+		}).
+		Where(Eq{"b": 2})
+
+	query, args, err := b.PlaceholderFormat(Dollar).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE a = $1 AND (created_at > $2 OR updated_at > $2) AND b = $3", query)
+	assert.Equal(t, []interface{}{1, 10, 2}, args)
+
+	query, args, err = b.PlaceholderFormat(Question).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE a = ? AND (created_at > ? OR updated_at > ?) AND b = ?", query)
+	assert.Equal(t, []interface{}{1, 10, 10, 2}, args)
+
+	query, args, err = b.PlaceholderFormat(NamedAtP).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE a = @p1 AND (created_at > @since OR updated_at > @since) AND b = @p3", query)
+	assert.Equal(t, []interface{}{1, sql.Named("since", 10), 2}, args)
+
+	query, args, err = b.PlaceholderFormat(NamedColon).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE a = :1 AND (created_at > :since OR updated_at > :since) AND b = :3", query)
+	assert.Equal(t, []interface{}{1, sql.Named("since", 10), 2}, args)
+}
+
+func TestNamedInBuilders(t *testing.T) {
+	params := map[string]interface{}{"tenant": 7}
+	query, args, err := Update("t").
+		Set("x", 1).
+		Where(Expr("tenant_id = :tenant", params)).
+		Suffix("RETURNING (SELECT name FROM tenants WHERE id = :tenant)", params).
+		PlaceholderFormat(Dollar).
+		ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE t SET x = $1 WHERE tenant_id = $2 RETURNING (SELECT name FROM tenants WHERE id = $2)", query)
+	assert.Equal(t, []interface{}{1, 7}, args)
+}
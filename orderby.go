@@ -0,0 +1,166 @@
+package squirrel2
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SortFields maps the public names of the fields a list can be sorted by to
+// their SQL, e.g. SortFields{"name": "users.name"}. It's the allowlist of an
+// OrderBySpec.
+type SortFields map[string]safeString
+
+// SortFieldError is returned by OrderBySpec.Parse for a sort it can't accept.
+type SortFieldError struct {
+	// Field is the field as given in the sort, without its direction.
+	Field string
+	// Reason says what is wrong with Field, e.g. "unknown sort field".
+	Reason string
+	// Allowed are the public names of the fields of the OrderBySpec, sorted.
+	Allowed []string
+}
+
+func (e *SortFieldError) Error() string {
+	return fmt.Sprintf("%s %q, expected one of: %s", e.Reason, e.Field, strings.Join(e.Allowed, ", "))
+}
+
+// OrderByTerm is an ORDER BY expression parsed by an OrderBySpec.
+type OrderByTerm struct {
+	// Field is the public name of the field, or "" for the tiebreaker.
+	Field string
+	// Expr is the SQL of the field, from the allowlist of the OrderBySpec.
+	Expr safeString
+	// Desc is set for descending order.
+	Desc bool
+}
+
+// SafeString returns the term as an ORDER BY expression, e.g. "users.name DESC".
+func (t OrderByTerm) SafeString() safeString {
+	if t.Desc {
+		return t.Expr + " DESC"
+	}
+	return t.Expr + " ASC"
+}
+
+// ToSql builds the term into an ORDER BY expression, so that it can be passed
+// to OrderByClause.
+func (t OrderByTerm) ToSql() (string, []interface{}, error) {
+	return t.SafeString().ToSql()
+}
+
+// OrderByTerms are the terms parsed by an OrderBySpec, in order.
+type OrderByTerms []OrderByTerm
+
+// SafeStrings returns the terms as ORDER BY expressions, to pass to OrderBy.
+func (ts OrderByTerms) SafeStrings() []safeString {
+	orderBys := make([]safeString, len(ts))
+	for i, t := range ts {
+		orderBys[i] = t.SafeString()
+	}
+	return orderBys
+}
+
+// OrderBySpec parses sorts given by users, e.g. in "?sort=name,-created_at",
+// into ORDER BY terms, accepting only the fields of its allowlist.
+//
+// Ex:
+//
+//	var usersSort = NewOrderBySpec(SortFields{
+//		"name":       "users.name",
+//		"created_at": "users.created_at",
+//	}).Default("-created_at").Tiebreaker("users.id")
+//
+//	terms, err := usersSort.Parse(r.URL.Query().Get("sort"))
+//	if err != nil {
+//		return err // e.g. unknown sort field "password", expected one of: created_at, name
+//	}
+//	q := Select("*").From("users").OrderBy(terms.SafeStrings()...)
+type OrderBySpec struct {
+	fields       SortFields
+	defaultOrder string
+	tiebreaker   safeString
+}
+
+// NewOrderBySpec returns an OrderBySpec accepting the fields of fields.
+func NewOrderBySpec(fields SortFields) OrderBySpec {
+	return OrderBySpec{fields: fields}
+}
+
+// Default sets the sort used when none is given, in the format of Parse,
+// e.g. "-created_at".
+//
+// Default panics if order isn't a valid sort of s: it's set by the programmer,
+// so Parse mustn't report it to users as a *SortFieldError of theirs.
+func (s OrderBySpec) Default(order string) OrderBySpec {
+	if _, err := (OrderBySpec{fields: s.fields}).Parse(order); err != nil {
+		panic(fmt.Errorf("invalid default sort %q: %w", order, err))
+	}
+	s.defaultOrder = order
+	return s
+}
+
+// Tiebreaker sets a column that is always sorted by last, in ascending order,
+// unless the sort already has it. A unique column makes the order of the rows
+// deterministic, which paginating with LIMIT and OFFSET needs.
+func (s OrderBySpec) Tiebreaker(column safeString) OrderBySpec {
+	s.tiebreaker = column
+	return s
+}
+
+// Parse parses order, a comma-separated list of public field names, each
+// optionally prefixed with "-" for descending order or "+" for ascending
+// order, which is the default. Spaces around the fields are ignored.
+//
+// Parse returns a *SortFieldError for fields that are unknown, empty or
+// repeated.
+func (s OrderBySpec) Parse(order string) (OrderByTerms, error) {
+	if strings.TrimSpace(order) == "" {
+		order = s.defaultOrder
+	}
+	var terms OrderByTerms
+	seen := make(map[string]bool)
+	if strings.TrimSpace(order) != "" {
+		for _, field := range strings.Split(order, ",") {
+			field = strings.TrimSpace(field)
+			term := OrderByTerm{Field: field}
+			if strings.HasPrefix(field, "-") {
+				term.Field, term.Desc = field[1:], true
+			} else if strings.HasPrefix(field, "+") {
+				term.Field = field[1:]
+			}
+
+			expr, ok := s.fields[term.Field]
+			switch {
+			case term.Field == "":
+				return nil, s.fieldError(term.Field, "empty sort field")
+			case !ok:
+				return nil, s.fieldError(term.Field, "unknown sort field")
+			case seen[term.Field]:
+				return nil, s.fieldError(term.Field, "duplicate sort field")
+			}
+			seen[term.Field] = true
+			term.Expr = expr
+			terms = append(terms, term)
+		}
+	}
+
+	if s.tiebreaker != "" {
+		for _, t := range terms {
+			if t.Expr == s.tiebreaker {
+				return terms, nil
+			}
+		}
+		terms = append(terms, OrderByTerm{Expr: s.tiebreaker})
+	}
+	return terms, nil
+}
+
+func (s OrderBySpec) fieldError(field, reason string) *SortFieldError {
+	allowed := make([]string, 0, len(s.fields))
+	for name := range s.fields {
+		allowed = append(allowed, name)
+	}
+	sort.Strings(allowed)
+	return &SortFieldError{Field: field, Reason: reason, Allowed: allowed}
+}
@@ -0,0 +1,78 @@
+package squirrel2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSort = NewOrderBySpec(SortFields{
+	"name":       "users.name",
+	"created_at": "users.created_at",
+	"id":         "users.id",
+}).Default("-created_at").Tiebreaker("users.id")
+
+func TestOrderBySpecParse(t *testing.T) {
+	terms, err := testSort.Parse("name, -created_at")
+	assert.NoError(t, err)
+	assert.Equal(t, OrderByTerms{
+		{Field: "name", Expr: "users.name"},
+		{Field: "created_at", Expr: "users.created_at", Desc: true},
+		{Expr: "users.id"},
+	}, terms)
+
+	sql, _, err := Select("*").From("users").OrderBy(terms.SafeStrings()...).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM users ORDER BY users.name ASC, users.created_at DESC, users.id ASC", sql)
+
+	// The tiebreaker isn't repeated.
+	terms, err = testSort.Parse("+name,-id")
+	assert.NoError(t, err)
+	assert.Equal(t, []safeString{"users.name ASC", "users.id DESC"}, terms.SafeStrings())
+}
+
+func TestOrderBySpecDefault(t *testing.T) {
+	terms, err := testSort.Parse(" ")
+	assert.NoError(t, err)
+	assert.Equal(t, []safeString{"users.created_at DESC", "users.id ASC"}, terms.SafeStrings())
+
+	terms, err = NewOrderBySpec(SortFields{"name": "name"}).Parse("")
+	assert.NoError(t, err)
+	assert.Empty(t, terms)
+
+	sql, _, err := Delete("users").OrderBy(OrderByTerm{Expr: "name", Desc: true}.SafeString()).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "DELETE FROM users ORDER BY name DESC", sql)
+
+	sql, _, err = Select("*").From("users").OrderByClause(OrderByTerm{Expr: "name"}).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM users ORDER BY name ASC", sql)
+
+	// Invalid defaults are bugs of the program, not of its users.
+	spec := NewOrderBySpec(SortFields{"name": "name"})
+	assert.PanicsWithError(t,
+		`invalid default sort "-nmae": unknown sort field "nmae", expected one of: name`,
+		func() { spec.Default("-nmae") })
+	assert.NotPanics(t, func() { spec.Default("") })
+}
+
+func TestOrderBySpecErrors(t *testing.T) {
+	for sort, msg := range map[string]string{
+		"password":    `unknown sort field "password", expected one of: created_at, id, name`,
+		"name,,id":    `empty sort field "", expected one of: created_at, id, name`,
+		"-":           `empty sort field "", expected one of: created_at, id, name`,
+		"name,-name":  `duplicate sort field "name", expected one of: created_at, id, name`,
+		"users.name":  `unknown sort field "users.name", expected one of: created_at, id, name`,
+		"name; DROP":  `unknown sort field "name; DROP", expected one of: created_at, id, name`,
+		"--name":      `unknown sort field "-name", expected one of: created_at, id, name`,
+		"name desc":   `unknown sort field "name desc", expected one of: created_at, id, name`,
+		"Name":        `unknown sort field "Name", expected one of: created_at, id, name`,
+		"created_at,": `empty sort field "", expected one of: created_at, id, name`,
+	} {
+		_, err := testSort.Parse(sort)
+		var fieldErr *SortFieldError
+		if assert.ErrorAs(t, err, &fieldErr, sort) {
+			assert.Equal(t, msg, err.Error())
+		}
+	}
+}
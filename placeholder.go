@@ -2,6 +2,7 @@ package squirrel2
 
 import (
 	"bytes"
+	"database/sql"
 	"fmt"
 	"strings"
 )
@@ -18,15 +19,33 @@ type placeholderDebugger interface {
 	debugPlaceholder() string
 }
 
+// argsPlaceholderFormat is implemented by the PlaceholderFormats of this
+// package, which need the args of a statement to number named args.
+type argsPlaceholderFormat interface {
+	replacePlaceholdersArgs(sql string, args []interface{}) (string, []interface{}, error)
+}
+
 var (
 	// Question is a PlaceholderFormat instance that leaves placeholders as
 	// question marks.
 	Question = questionFormat{}
 
+	// MySQLQuestion is like Question, for MySQL and MariaDB, whose string
+	// literals use backslashes to escape characters (e.g. 'it\'s?'). Question
+	// would end such literals early, and count the question marks inside of
+	// them as placeholders.
+	MySQLQuestion = mysqlQuestionFormat{}
+
 	// Dollar is a PlaceholderFormat instance that replaces placeholders with
 	// dollar-prefixed positional placeholders (e.g. $1, $2, $3).
 	Dollar = dollarFormat{}
 
+	// DollarJSONB is like Dollar, but leaves the Postgres jsonb operators ?|
+	// and ?& alone instead of reading them as a placeholder followed by | or
+	// &, so they don't need to be escaped as ??| and ??&. A placeholder
+	// followed by the || operator must then be written "? ||".
+	DollarJSONB = dollarJSONBFormat{}
+
 	// Colon is a PlaceholderFormat instance that replaces placeholders with
 	// colon-prefixed positional placeholders (e.g. :1, :2, :3).
 	Colon = colonFormat{}
@@ -34,6 +53,16 @@ var (
 	// AtP is a PlaceholderFormat instance that replaces placeholders with
 	// "@p"-prefixed positional placeholders (e.g. @p1, @p2, @p3).
 	AtP = atpFormat{}
+
+	// NamedAtP is like AtP, but passes named args through to the driver as
+	// sql.NamedArgs referenced by "@"-prefixed names (e.g. @id), for drivers
+	// like SQL Server's that support them.
+	NamedAtP = namedAtPFormat{}
+
+	// NamedColon is like Colon, but passes named args through to the driver as
+	// sql.NamedArgs referenced by colon-prefixed names (e.g. :id), for drivers
+	// like Oracle's that support them.
+	NamedColon = namedColonFormat{}
 )
 
 type questionFormat struct{}
@@ -46,6 +75,8 @@ func (questionFormat) debugPlaceholder() string {
 	return "?"
 }
 
+type mysqlQuestionFormat struct{ questionFormat }
+
 type dollarFormat struct{}
 
 func (dollarFormat) ReplacePlaceholders(sql string) (string, error) {
@@ -56,6 +87,25 @@ func (dollarFormat) debugPlaceholder() string {
 	return "$"
 }
 
+func (dollarFormat) replacePlaceholdersArgs(sql string, args []interface{}) (string, []interface{}, error) {
+	return rewritePlaceholders(sqlLexer{}, sql, args, "$", "")
+}
+
+type dollarJSONBFormat struct{}
+
+func (dollarJSONBFormat) ReplacePlaceholders(sql string) (string, error) {
+	sql, _, err := rewritePlaceholders(sqlLexer{jsonbOperators: true}, sql, nil, "$", "")
+	return sql, err
+}
+
+func (dollarJSONBFormat) debugPlaceholder() string {
+	return "$"
+}
+
+func (dollarJSONBFormat) replacePlaceholdersArgs(sql string, args []interface{}) (string, []interface{}, error) {
+	return rewritePlaceholders(sqlLexer{jsonbOperators: true}, sql, args, "$", "")
+}
+
 type colonFormat struct{}
 
 func (colonFormat) ReplacePlaceholders(sql string) (string, error) {
@@ -66,6 +116,10 @@ func (colonFormat) debugPlaceholder() string {
 	return ":"
 }
 
+func (colonFormat) replacePlaceholdersArgs(sql string, args []interface{}) (string, []interface{}, error) {
+	return rewritePlaceholders(sqlLexer{}, sql, args, ":", "")
+}
+
 type atpFormat struct{}
 
 func (atpFormat) ReplacePlaceholders(sql string) (string, error) {
@@ -76,39 +130,141 @@ func (atpFormat) debugPlaceholder() string {
 	return "@p"
 }
 
+func (atpFormat) replacePlaceholdersArgs(sql string, args []interface{}) (string, []interface{}, error) {
+	return rewritePlaceholders(sqlLexer{}, sql, args, "@p", "")
+}
+
+type namedAtPFormat struct{}
+
+func (namedAtPFormat) ReplacePlaceholders(sql string) (string, error) {
+	return replacePositionalPlaceholders(sql, "@p")
+}
+
+func (namedAtPFormat) debugPlaceholder() string {
+	return "@p"
+}
+
+func (namedAtPFormat) replacePlaceholdersArgs(sql string, args []interface{}) (string, []interface{}, error) {
+	return rewritePlaceholders(sqlLexer{}, sql, args, "@p", "@")
+}
+
+type namedColonFormat struct{}
+
+func (namedColonFormat) ReplacePlaceholders(sql string) (string, error) {
+	return replacePositionalPlaceholders(sql, ":")
+}
+
+func (namedColonFormat) debugPlaceholder() string {
+	return ":"
+}
+
+func (namedColonFormat) replacePlaceholdersArgs(sql string, args []interface{}) (string, []interface{}, error) {
+	return rewritePlaceholders(sqlLexer{}, sql, args, ":", ":")
+}
+
 // Placeholders returns a string with count ? placeholders joined with commas.
 func Placeholders(count int) safeString {
 	if count < 1 {
 		return ""
 	}
 
+	//squirrelvet:allow made of placeholders only
 	return safeString(strings.Repeat(",?", count)[1:])
 }
 
-func replacePositionalPlaceholders(sql, prefix string) (string, error) {
-	buf := &bytes.Buffer{}
-	i := 0
-	for {
-		p := strings.Index(sql, "?")
-		if p == -1 {
-			break
+// replacePlaceholders finalizes the placeholders of the raw SQL of a
+// statement, after checking that there is exactly one arg per placeholder.
+// Named args are numbered by f, or bound to each of their placeholders if f
+// can't number them.
+func replacePlaceholders(f PlaceholderFormat, sql string, args []interface{}) (string, []interface{}, error) {
+	count := 0
+	err := lexerFor(f).scan(sql, func(tok sqlToken) error {
+		if tok.kind == sqlPlaceholder {
+			count++
 		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if count != len(args) {
+		return "", nil, fmt.Errorf("sql has %d placeholders but %d args were given", count, len(args))
+	}
+	if af, ok := f.(argsPlaceholderFormat); ok {
+		return af.replacePlaceholdersArgs(sql, args)
+	}
+	sql, args, _ = resolveNamedArgs(sql, args, nil)
+	sql, err = f.ReplacePlaceholders(sql)
+	return sql, args, err
+}
 
-		if len(sql[p:]) > 1 && sql[p:p+2] == "??" { // escape ?? => ?
-			buf.WriteString(sql[:p])
-			buf.WriteString("?")
-			if len(sql[p:]) == 1 {
-				break
+// replacePositionalPlaceholders numbers each "?" placeholder of sql, skipping
+// the ones inside of string literals, quoted identifiers and comments, and
+// unescapes each "??" into "?".
+func replacePositionalPlaceholders(sql, prefix string) (string, error) {
+	sql, _, err := rewritePlaceholders(sqlLexer{}, sql, nil, prefix, "")
+	return sql, err
+}
+
+// rewritePlaceholders is replacePositionalPlaceholders for a statement with
+// known args, scanned by l. Every occurrence of a named arg gets the number of the first
+// one, unless namedPrefix is set, in which case named args are referenced by
+// name and passed through as sql.NamedArgs.
+func rewritePlaceholders(l sqlLexer, query string, args []interface{}, prefix, namedPrefix string) (string, []interface{}, error) {
+	buf := &bytes.Buffer{}
+	var (
+		out   []interface{}
+		n     int
+		i     int
+		named namedNumbers
+	)
+	err := l.scan(query, func(tok sqlToken) error {
+		switch tok.kind {
+		case sqlPlaceholder:
+			var arg interface{}
+			if i < len(args) {
+				arg = args[i]
 			}
-			sql = sql[p+2:]
-		} else {
 			i++
-			buf.WriteString(sql[:p])
-			fmt.Fprintf(buf, "%s%d", prefix, i)
-			sql = sql[p+1:]
+
+			na, ok := arg.(namedArg)
+			if !ok {
+				n++
+				if args != nil {
+					out = append(out, arg)
+				}
+				fmt.Fprintf(buf, "%s%d", prefix, n)
+				return nil
+			}
+
+			num, err := named.lookup(na)
+			if err != nil {
+				return err
+			}
+			if num == 0 {
+				n++
+				num = n
+				named.add(na, num)
+				if namedPrefix != "" {
+					out = append(out, sql.Named(na.name, na.value))
+				} else {
+					out = append(out, na.value)
+				}
+			}
+			if namedPrefix != "" {
+				buf.WriteString(namedPrefix + na.name)
+			} else {
+				fmt.Fprintf(buf, "%s%d", prefix, num)
+			}
+		case sqlEscapedPlaceholder:
+			buf.WriteString("?")
+		default:
+			buf.WriteString(tok.text)
 		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
 	}
-
-	buf.WriteString(sql)
-	return buf.String(), nil
+	return buf.String(), out, nil
 }
@@ -38,19 +38,78 @@ func TestPlaceholders(t *testing.T) {
 func TestEscapeDollar(t *testing.T) {
 	sql := "SELECT uuid, \"data\" #> '{tags}' AS tags FROM nodes WHERE  \"data\" -> 'tags' ??| array['?'] AND enabled = ?"
 	s, _ := Dollar.ReplacePlaceholders(sql)
-	assert.Equal(t, "SELECT uuid, \"data\" #> '{tags}' AS tags FROM nodes WHERE  \"data\" -> 'tags' ?| array['$1'] AND enabled = $2", s)
+	assert.Equal(t, "SELECT uuid, \"data\" #> '{tags}' AS tags FROM nodes WHERE  \"data\" -> 'tags' ?| array['?'] AND enabled = $1", s)
 }
 
 func TestEscapeColon(t *testing.T) {
 	sql := "SELECT uuid, \"data\" #> '{tags}' AS tags FROM nodes WHERE  \"data\" -> 'tags' ??| array['?'] AND enabled = ?"
 	s, _ := Colon.ReplacePlaceholders(sql)
-	assert.Equal(t, "SELECT uuid, \"data\" #> '{tags}' AS tags FROM nodes WHERE  \"data\" -> 'tags' ?| array[':1'] AND enabled = :2", s)
+	assert.Equal(t, "SELECT uuid, \"data\" #> '{tags}' AS tags FROM nodes WHERE  \"data\" -> 'tags' ?| array['?'] AND enabled = :1", s)
 }
 
 func TestEscapeAtp(t *testing.T) {
 	sql := "SELECT uuid, \"data\" #> '{tags}' AS tags FROM nodes WHERE  \"data\" -> 'tags' ??| array['?'] AND enabled = ?"
 	s, _ := AtP.ReplacePlaceholders(sql)
-	assert.Equal(t, "SELECT uuid, \"data\" #> '{tags}' AS tags FROM nodes WHERE  \"data\" -> 'tags' ?| array['@p1'] AND enabled = @p2", s)
+	assert.Equal(t, "SELECT uuid, \"data\" #> '{tags}' AS tags FROM nodes WHERE  \"data\" -> 'tags' ?| array['?'] AND enabled = @p1", s)
+}
+
+func TestPlaceholdersInLiteralsAndComments(t *testing.T) {
+	sql := "SELECT 'what?', \"col?\", `col?`, E'it\\'s?', $$ body? $$, $fn$ ? $fn$ " +
+		"-- comment?\n" +
+		"FROM t /* block? /* nested? */ */ WHERE a = ? AND b = ?"
+	s, err := Dollar.ReplacePlaceholders(sql)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT 'what?', \"col?\", `col?`, E'it\\'s?', $$ body? $$, $fn$ ? $fn$ "+
+		"-- comment?\n"+
+		"FROM t /* block? /* nested? */ */ WHERE a = $1 AND b = $2", s)
+}
+
+func TestDollarJsonbOperators(t *testing.T) {
+	sql := "SELECT * FROM t WHERE data ?| array['a'] AND data ?& array['b'] AND data ?? 'c' AND name = ?||'x'"
+	s, err := DollarJSONB.ReplacePlaceholders(sql)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE data ?| array['a'] AND data ?& array['b'] AND data ? 'c' AND name = $1||'x'", s)
+
+	s, args, err := Select("*").From("t").Where(Expr("data ?| array[?]", "a")).PlaceholderFormat(DollarJSONB).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE data ?| array[$1]", s)
+	assert.Equal(t, []interface{}{"a"}, args)
+
+	// Dollar reads them as placeholders followed by | and &.
+	s, err = Dollar.ReplacePlaceholders("x = ?|y AND z = ?&w")
+	assert.NoError(t, err)
+	assert.Equal(t, "x = $1|y AND z = $2&w", s)
+
+	s, err = Colon.ReplacePlaceholders("x = ?|y")
+	assert.NoError(t, err)
+	assert.Equal(t, "x = :1|y", s)
+}
+
+func TestMySQLQuestionBackslashEscapes(t *testing.T) {
+	s, args, err := Select("*").From("t").
+		Where(Expr(`a = 'it\'s ?' AND b = "say \"?\"" AND c = ?`, 1)).
+		PlaceholderFormat(MySQLQuestion).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM t WHERE a = 'it\'s ?' AND b = "say \"?\"" AND c = ?`, s)
+	assert.Equal(t, []interface{}{1}, args)
+
+	// Question ends the literal at the escaped quote.
+	_, _, err = replacePlaceholders(Question, `a = 'it\'s ?'`, nil)
+	assert.EqualError(t, err, "sql has 1 placeholders but 0 args were given")
+	_, _, err = replacePlaceholders(MySQLQuestion, `a = 'it\'s ?'`, nil)
+	assert.NoError(t, err)
+}
+
+func TestReplacePlaceholdersArgCount(t *testing.T) {
+	s, _, err := replacePlaceholders(Dollar, "x = ? AND y = '?'", []interface{}{1})
+	assert.NoError(t, err)
+	assert.Equal(t, "x = $1 AND y = '?'", s)
+
+	_, _, err = replacePlaceholders(Dollar, "x = ? AND y = ?", []interface{}{1})
+	assert.EqualError(t, err, "sql has 2 placeholders but 1 args were given")
+
+	_, _, err = replacePlaceholders(Question, "x = ?", []interface{}{1, 2})
+	assert.EqualError(t, err, "sql has 1 placeholders but 2 args were given")
 }
 
 func BenchmarkPlaceholdersArray(b *testing.B) {
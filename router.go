@@ -0,0 +1,287 @@
+package squirrel2
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicaPolicy is the way a Router picks the replica a read is sent to.
+type ReplicaPolicy int
+
+const (
+	// RoundRobin sends reads to each replica in turn.
+	RoundRobin ReplicaPolicy = iota
+	// LeastLatency sends reads to the replica whose recent reads were the
+	// fastest. Replicas that haven't served a read yet are tried first. The
+	// latency of a replica fades as it goes without reads, so that a replica
+	// that was slow for a while is tried again.
+	LeastLatency
+)
+
+// RouterOptions configures a Router.
+type RouterOptions struct {
+	// Policy picks the replica of each read.
+	Policy ReplicaPolicy
+	// StickyWindow is how long the reads run with a context returned by
+	// WithRoutingSession go to the primary after a write was run with it, so
+	// that they see their own writes despite replication lag.
+	StickyWindow time.Duration
+	// LatencyHalfLife is how long it takes the latency of a replica to halve
+	// when it serves no reads, with the LeastLatency policy. Zero means 10
+	// seconds.
+	LatencyHalfLife time.Duration
+}
+
+// defaultLatencyHalfLife is used when RouterOptions.LatencyHalfLife is zero.
+const defaultLatencyHalfLife = 10 * time.Second
+
+// ErrNoTxSupport is returned by Router.BeginTx if its primary can't begin
+// transactions.
+var ErrNoTxSupport = errors.New("cannot begin a transaction; primary is not a TxBeginner")
+
+// Router is a RunnerContext splitting reads and writes between a primary
+// database and its read replicas.
+//
+// Queries that are plain SELECTs, without a locking clause (e.g. FOR UPDATE)
+// or an INTO, are sent to a replica. Everything else goes to the primary:
+// Execs, other statements, and the queries run with a context returned by
+// WithPrimary, e.g. by the Context methods of a builder with the Primary hint
+// set. Router is also a TxBeginner beginning transactions on the primary, so
+// that WithTx(ctx, router, ...) runs the whole transaction there.
+//
+// Ex:
+//
+//	router := NewRouter(primaryDB, []StdSqlCtx{replicaDB1, replicaDB2}, RouterOptions{})
+//	rows, err := Select("*").From("users").RunWith(router).QueryContext(ctx)
+type Router struct {
+	primary  StdSqlCtx
+	replicas []*replica
+	opts     RouterOptions
+	next     atomic.Uint64
+}
+
+var _ RunnerContext = (*Router)(nil)
+var _ TxBeginner = (*Router)(nil)
+
+type replica struct {
+	db StdSqlCtx
+	// latency is the moving average of the duration of the reads sent to
+	// db, in nanoseconds, or 0 before the first one.
+	latency atomic.Int64
+	// observedAt is the time of the last read sent to db, in Unix
+	// nanoseconds.
+	observedAt atomic.Int64
+}
+
+// NewRouter returns a Router running writes with primary and reads with
+// replicas. All statements are run with primary if there are no replicas.
+func NewRouter(primary StdSqlCtx, replicas []StdSqlCtx, opts RouterOptions) *Router {
+	if opts.LatencyHalfLife <= 0 {
+		opts.LatencyHalfLife = defaultLatencyHalfLife
+	}
+	r := &Router{primary: primary, opts: opts}
+	for _, db := range replicas {
+		r.replicas = append(r.replicas, &replica{db: db})
+	}
+	return r
+}
+
+type primaryKey struct{}
+
+// WithPrimary returns a copy of ctx making a Router send the statements run
+// with it to its primary.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+type routingSessionKey struct{}
+
+type routingSession struct {
+	mu        sync.Mutex
+	lastWrite time.Time
+}
+
+// WithRoutingSession returns a copy of ctx in which a Router remembers the
+// writes it runs, sending the following reads to its primary for
+// RouterOptions.StickyWindow.
+//
+// Ex:
+//
+//	ctx = WithRoutingSession(ctx)
+//	_, err := Insert("users").Columns("name").Values("ann").RunWith(router).ExecContext(ctx)
+//	// Reads the new user from the primary.
+//	user, err := ScanOne[User](ctx, Select("*").From("users").RunWith(router))
+func WithRoutingSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, routingSessionKey{}, &routingSession{})
+}
+
+// readQueryRegexp matches the statements that may be sent to a replica.
+var readQueryRegexp = regexp.MustCompile(`(?i)^[\s(]*SELECT\b`)
+
+// lockingClauseRegexp matches the clauses that make a SELECT lock rows or
+// write, in MySQL, Postgres and SQL Server syntaxes.
+var lockingClauseRegexp = regexp.MustCompile(
+	`(?i)\bFOR\s+(?:NO\s+KEY\s+)?UPDATE\b|\bFOR\s+(?:KEY\s+)?SHARE\b|\bLOCK\s+IN\s+SHARE\s+MODE\b|` +
+		`\bINTO\b|\b(?:UPDLOCK|XLOCK|HOLDLOCK|TABLOCKX?)\b`)
+
+// isReadQuery reports whether query is a SELECT that neither locks rows nor
+// writes, ignoring the contents of its literals, quoted identifiers and
+// comments.
+func isReadQuery(query string) bool {
+	var text strings.Builder
+	_ = sqlLexer{}.scan(query, func(tok sqlToken) error {
+		if tok.kind == sqlQuoted {
+			text.WriteByte(' ')
+		} else {
+			text.WriteString(tok.text)
+		}
+		return nil
+	})
+	s := text.String()
+	return readQueryRegexp.MatchString(s) && !lockingClauseRegexp.MatchString(s)
+}
+
+// reader returns the replica to send the read query to, or nil if it must go
+// to the primary.
+func (r *Router) reader(ctx context.Context, query string) *replica {
+	if len(r.replicas) == 0 || !isReadQuery(query) {
+		return nil
+	}
+	if primary, _ := ctx.Value(primaryKey{}).(bool); primary {
+		return nil
+	}
+	if s, ok := ctx.Value(routingSessionKey{}).(*routingSession); ok && r.opts.StickyWindow > 0 {
+		s.mu.Lock()
+		lastWrite := s.lastWrite
+		s.mu.Unlock()
+		if !lastWrite.IsZero() && time.Since(lastWrite) < r.opts.StickyWindow {
+			return nil
+		}
+	}
+
+	if r.opts.Policy == LeastLatency {
+		now := time.Now()
+		var best *replica
+		var bestLatency float64
+		for _, rep := range r.replicas {
+			latency := rep.decayedLatency(now, r.opts.LatencyHalfLife)
+			if latency == 0 {
+				return rep
+			}
+			if best == nil || latency < bestLatency {
+				best, bestLatency = rep, latency
+			}
+		}
+		return best
+	}
+	return r.replicas[(r.next.Add(1)-1)%uint64(len(r.replicas))]
+}
+
+// observe folds the duration of a read into the latency of rep.
+func (rep *replica) observe(d time.Duration) {
+	if d <= 0 {
+		d = 1
+	}
+	rep.observedAt.Store(time.Now().UnixNano())
+	for {
+		old := rep.latency.Load()
+		latency := int64(d)
+		if old != 0 {
+			latency = old + (int64(d)-old)/5
+		}
+		if rep.latency.CompareAndSwap(old, latency) {
+			return
+		}
+	}
+}
+
+// decayedLatency returns the latency of rep, halved for every halfLife
+// elapsed since its last read at now.
+func (rep *replica) decayedLatency(now time.Time, halfLife time.Duration) float64 {
+	latency := rep.latency.Load()
+	if latency == 0 {
+		return 0
+	}
+	age := now.Sub(time.Unix(0, rep.observedAt.Load()))
+	if age <= 0 {
+		return float64(latency)
+	}
+	return float64(latency) * math.Pow(0.5, float64(age)/float64(halfLife))
+}
+
+// wrote records a write run with ctx, for the stickiness of its session.
+func (r *Router) wrote(ctx context.Context) {
+	if s, ok := ctx.Value(routingSessionKey{}).(*routingSession); ok {
+		s.mu.Lock()
+		s.lastWrite = time.Now()
+		s.mu.Unlock()
+	}
+}
+
+// Exec runs query with the primary.
+func (r *Router) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return r.ExecContext(context.Background(), query, args...)
+}
+
+// Query runs query with a replica if it's a read, or with the primary.
+func (r *Router) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return r.QueryContext(context.Background(), query, args...)
+}
+
+// QueryRow runs query with a replica if it's a read, or with the primary.
+func (r *Router) QueryRow(query string, args ...interface{}) RowScanner {
+	return r.QueryRowContext(context.Background(), query, args...)
+}
+
+// ExecContext runs query with the primary.
+func (r *Router) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	r.wrote(ctx)
+	return r.primary.ExecContext(ctx, query, args...)
+}
+
+// QueryContext runs query with a replica if it's a read, or with the primary.
+func (r *Router) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rep := r.reader(ctx, query)
+	if rep == nil {
+		if !isReadQuery(query) {
+			r.wrote(ctx)
+		}
+		return r.primary.QueryContext(ctx, query, args...)
+	}
+	start := time.Now()
+	rows, err := rep.db.QueryContext(ctx, query, args...)
+	rep.observe(time.Since(start))
+	return rows, err
+}
+
+// QueryRowContext runs query with a replica if it's a read, or with the
+// primary.
+func (r *Router) QueryRowContext(ctx context.Context, query string, args ...interface{}) RowScanner {
+	rep := r.reader(ctx, query)
+	if rep == nil {
+		if !isReadQuery(query) {
+			r.wrote(ctx)
+		}
+		return r.primary.QueryRowContext(ctx, query, args...)
+	}
+	start := time.Now()
+	row := rep.db.QueryRowContext(ctx, query, args...)
+	rep.observe(time.Since(start))
+	return row
+}
+
+// BeginTx begins a transaction on the primary.
+func (r *Router) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	db, ok := r.primary.(TxBeginner)
+	if !ok {
+		return nil, ErrNoTxSupport
+	}
+	return db.BeginTx(ctx, opts)
+}
@@ -0,0 +1,182 @@
+package squirrel2
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRouter returns a Router over fake databases, and those databases.
+func newTestRouter(opts RouterOptions, replicas int) (*Router, *fakeDB, []*fakeDB) {
+	primaryDB, primary := newFakeDB(fakeResult{columns: []string{"x"}})
+	var dbs []StdSqlCtx
+	var fakes []*fakeDB
+	for i := 0; i < replicas; i++ {
+		db, fake := newFakeDB(fakeResult{columns: []string{"x"}})
+		dbs = append(dbs, db)
+		fakes = append(fakes, fake)
+	}
+	return NewRouter(primaryDB, dbs, opts), primary, fakes
+}
+
+func TestIsReadQuery(t *testing.T) {
+	tests := map[string]bool{
+		"SELECT * FROM t":                                 true,
+		"  select * from t":                               true,
+		"(SELECT a FROM t) UNION (SELECT a FROM u)":       true,
+		"SELECT * FROM t WHERE note = 'FOR UPDATE'":       true,
+		"SELECT * FROM t /* INTO */":                      true,
+		`SELECT "into" FROM t`:                            true,
+		"SELECT * FROM t FOR UPDATE":                      false,
+		"SELECT * FROM t FOR NO KEY UPDATE SKIP LOCKED":   false,
+		"SELECT * FROM t FOR SHARE":                       false,
+		"SELECT * FROM t LOCK IN SHARE MODE":              false,
+		"SELECT * FROM t WITH (UPDLOCK) WHERE id = ?":     false,
+		"SELECT * INTO backup FROM t":                     false,
+		"INSERT INTO t (a) SELECT a FROM u":               false,
+		"UPDATE t SET a = 1":                              false,
+		"WITH d AS (DELETE FROM t RETURNING *) SELECT 1":  false,
+		"/* SELECT */ DELETE FROM t":                      false,
+		"SELECT pg_advisory_lock(1) FROM t FOR KEY SHARE": false,
+	}
+	for query, expected := range tests {
+		assert.Equal(t, expected, isReadQuery(query), query)
+	}
+}
+
+func TestRouterRoundRobin(t *testing.T) {
+	router, primary, replicas := newTestRouter(RouterOptions{}, 2)
+	b := Select("x").From("t").RunWith(router)
+
+	for i := 0; i < 3; i++ {
+		rows, err := b.QueryContext(context.Background())
+		assert.NoError(t, err)
+		rows.Close()
+	}
+	var x int
+	assert.Equal(t, sql.ErrNoRows, b.Scan(&x))
+
+	assert.Empty(t, primary.statements())
+	assert.Len(t, replicas[0].statements(), 2)
+	assert.Len(t, replicas[1].statements(), 2)
+}
+
+func TestRouterWrites(t *testing.T) {
+	router, primary, replicas := newTestRouter(RouterOptions{}, 1)
+
+	_, err := Update("t").Set("x", 1).RunWith(router).ExecContext(context.Background())
+	assert.NoError(t, err)
+	rows, err := Select("x").From("t").Suffix("FOR UPDATE").RunWith(router).Query()
+	assert.NoError(t, err)
+	rows.Close()
+	rows, err = Insert("t").Columns("x").Values(1).Suffix("RETURNING x").RunWith(router).Query()
+	assert.NoError(t, err)
+	rows.Close()
+
+	assert.Equal(t, []string{
+		"UPDATE t SET x = ?",
+		"SELECT x FROM t FOR UPDATE",
+		"INSERT INTO t (x) VALUES (?) RETURNING x",
+	}, primary.statements())
+	assert.Empty(t, replicas[0].statements())
+}
+
+func TestRouterPrimaryHint(t *testing.T) {
+	router, primary, replicas := newTestRouter(RouterOptions{}, 1)
+	b := Select("x").From("t").RunWith(router).Primary()
+
+	rows, err := b.QueryContext(context.Background())
+	assert.NoError(t, err)
+	rows.Close()
+	rows, err = b.Query()
+	assert.NoError(t, err)
+	rows.Close()
+	var x int
+	assert.Equal(t, sql.ErrNoRows, b.Scan(&x))
+	_, err = ScanAll[int](context.Background(), b)
+	assert.NoError(t, err)
+	rows, err = router.QueryContext(WithPrimary(context.Background()), "SELECT x FROM t")
+	assert.NoError(t, err)
+	rows.Close()
+
+	assert.Len(t, primary.statements(), 5)
+	assert.Empty(t, replicas[0].statements())
+}
+
+func TestRouterLeastLatency(t *testing.T) {
+	router, _, replicas := newTestRouter(RouterOptions{Policy: LeastLatency}, 3)
+	router.replicas[0].observe(3 * time.Millisecond)
+	router.replicas[1].observe(time.Millisecond)
+
+	// The replica without latency is tried first.
+	rows, err := router.Query("SELECT x FROM t")
+	assert.NoError(t, err)
+	rows.Close()
+	assert.Len(t, replicas[2].statements(), 1)
+
+	router.replicas[2].latency.Store(int64(2 * time.Millisecond))
+	rows, err = router.Query("SELECT x FROM t")
+	assert.NoError(t, err)
+	rows.Close()
+	assert.Len(t, replicas[1].statements(), 1)
+	assert.Empty(t, replicas[0].statements())
+
+	// A replica that was slow is tried again once its latency fades.
+	router.replicas[0].observe(100 * time.Millisecond)
+	router.replicas[0].observedAt.Store(time.Now().Add(-time.Minute).UnixNano())
+	rows, err = router.Query("SELECT x FROM t")
+	assert.NoError(t, err)
+	rows.Close()
+	assert.Len(t, replicas[0].statements(), 1)
+
+	rep := &replica{}
+	rep.observe(10 * time.Millisecond)
+	rep.observe(20 * time.Millisecond)
+	assert.Equal(t, int64(12*time.Millisecond), rep.latency.Load())
+}
+
+func TestRouterStickyAfterWrite(t *testing.T) {
+	router, primary, replicas := newTestRouter(RouterOptions{StickyWindow: time.Minute}, 1)
+	ctx := WithRoutingSession(context.Background())
+	b := Select("x").From("t").RunWith(router)
+
+	// Reads go to the replica until a write is run in the session.
+	var x int
+	assert.Equal(t, sql.ErrNoRows, b.ScanContext(ctx, &x))
+	_, err := Delete("t").RunWith(router).ExecContext(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, sql.ErrNoRows, b.ScanContext(ctx, &x))
+	// Other contexts aren't affected.
+	assert.Equal(t, sql.ErrNoRows, b.ScanContext(context.Background(), &x))
+
+	assert.Equal(t, []string{"DELETE FROM t", "SELECT x FROM t"}, primary.statements())
+	assert.Len(t, replicas[0].statements(), 2)
+
+	// Once the window is over, reads go back to the replica.
+	session := ctx.Value(routingSessionKey{}).(*routingSession)
+	session.lastWrite = time.Now().Add(-time.Hour)
+	assert.Equal(t, sql.ErrNoRows, b.ScanContext(ctx, &x))
+	assert.Len(t, replicas[0].statements(), 3)
+}
+
+func TestRouterTx(t *testing.T) {
+	router, primary, replicas := newTestRouter(RouterOptions{}, 1)
+
+	err := WithTx(context.Background(), router, nil, func(tx RunnerContext) error {
+		var x int
+		assert.Equal(t, sql.ErrNoRows, Select("x").From("t").RunWith(tx).Scan(&x))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"BEGIN", "SELECT x FROM t", "COMMIT"}, primary.statements())
+	assert.Empty(t, replicas[0].statements())
+
+	// Hide the BeginTx method of the primary.
+	db, _ := newFakeDB(fakeResult{})
+	router = NewRouter(struct{ StdSqlCtx }{db}, nil, RouterOptions{})
+	_, err = router.BeginTx(context.Background(), nil)
+	assert.Equal(t, ErrNoTxSupport, err)
+}
@@ -0,0 +1,104 @@
+package squirrel2
+
+import (
+	"context"
+	"database/sql"
+	"iter"
+	"reflect"
+)
+
+// Rows runs the query of q, usually a builder returned by Select, and returns
+// an iterator over its result, scanning each row into a T as ScanAll does.
+//
+// Rows are streamed rather than buffered, and closed when the iteration ends,
+// even if it stops early. A failure, including that of the query, is yielded
+// once with the zero T and ends the iteration.
+//
+// Ex:
+//
+//	for user, err := range Rows[User](ctx, Select("*").From("users").RunWith(db)) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func Rows[T any](ctx context.Context, q contextQueryer) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		ctx, timeout := withRowsTimeout(ctx)
+		rows, err := q.QueryContext(ctx)
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+		yieldRows(rows, timeout, yield, func(columns []string) (func(*sql.Rows) (T, error), error) {
+			dest, err := newRowScanner(reflect.TypeOf((*T)(nil)).Elem(), columns)
+			if err != nil {
+				return nil, err
+			}
+			return func(rows *sql.Rows) (value T, err error) {
+				err = rows.Scan(dest.targets(reflect.ValueOf(&value).Elem())...)
+				return value, err
+			}, nil
+		})
+	}
+}
+
+// RowsFunc is like Rows, but scans each row with scan.
+//
+// Ex:
+//
+//	names := RowsFunc(ctx, Select("name").From("users").RunWith(db),
+//		func(rows *sql.Rows) (name string, err error) {
+//			err = rows.Scan(&name)
+//			return name, err
+//		})
+func RowsFunc[T any](ctx context.Context, q contextQueryer, scan func(*sql.Rows) (T, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		ctx, timeout := withRowsTimeout(ctx)
+		rows, err := q.QueryContext(ctx)
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+		yieldRows(rows, timeout, yield, func([]string) (func(*sql.Rows) (T, error), error) {
+			return scan, nil
+		})
+	}
+}
+
+// yieldRows yields the rows scanned by the func returned by newScan for their
+// columns, until yield returns false, and closes rows and ends their timeout.
+func yieldRows[T any](rows *sql.Rows, timeout *rowsTimeout, yield func(T, error) bool, newScan func(columns []string) (func(*sql.Rows) (T, error), error)) {
+	// Also closes rows and ends their timeout if the loop body panics.
+	defer timeout.end(nil)
+	defer rows.Close()
+	err := func() error {
+		columns, err := rows.Columns()
+		if err != nil {
+			return err
+		}
+		scan, err := newScan(columns)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			value, err := scan(rows)
+			if err != nil {
+				return err
+			}
+			if !yield(value, nil) {
+				return nil
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return rows.Close()
+	}()
+	if err = timeout.end(err); err != nil {
+		var zero T
+		yield(zero, err)
+	}
+}
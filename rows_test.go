@@ -0,0 +1,103 @@
+package squirrel2
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRows(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{
+		columns: []string{"id", "name"},
+		rows:    [][]driver.Value{{int64(1), "ann"}, {int64(2), "bob"}, {int64(3), "cid"}},
+	})
+	type user struct {
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	b := Select("id", "name").From("users").RunWith(db)
+
+	var users []user
+	for u, err := range Rows[user](context.Background(), b) {
+		assert.NoError(t, err)
+		users = append(users, u)
+	}
+	assert.Equal(t, []user{{1, "ann"}, {2, "bob"}, {3, "cid"}}, users)
+	assert.Equal(t, 0, fake.openRows)
+
+	// Rows are closed when the iteration stops early.
+	var names []string
+	for u, err := range Rows[*user](context.Background(), b) {
+		assert.NoError(t, err)
+		names = append(names, u.Name)
+		if len(names) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []string{"ann", "bob"}, names)
+	assert.Equal(t, 0, fake.openRows)
+}
+
+func TestRowsErrors(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{
+		columns: []string{"id", "name"},
+		rows:    [][]driver.Value{{int64(1), "ann"}},
+	})
+	b := Select("id", "name").From("users").RunWith(db)
+
+	var errs []error
+	for _, err := range Rows[int64](context.Background(), b) {
+		errs = append(errs, err)
+	}
+	if assert.Len(t, errs, 1) {
+		assert.EqualError(t, errs[0], "cannot scan 2 columns into int64")
+	}
+	assert.Equal(t, 0, fake.openRows)
+
+	boom := errors.New("boom")
+	fake.execErr = func(string) error { return boom }
+	errs = nil
+	for _, err := range Rows[int64](context.Background(), b) {
+		errs = append(errs, err)
+	}
+	assert.Equal(t, []error{boom}, errs)
+
+	errs = nil
+	for _, err := range Rows[int64](context.Background(), Select("id").From("users")) {
+		errs = append(errs, err)
+	}
+	assert.Equal(t, []error{ErrRunnerNotSet}, errs)
+}
+
+func TestRowsFunc(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{
+		columns: []string{"name"},
+		rows:    [][]driver.Value{{"ann"}, {"bob"}},
+	})
+	b := Select("name").From("users").RunWith(db)
+
+	var names []string
+	for name, err := range RowsFunc(context.Background(), b, func(rows *sql.Rows) (name string, err error) {
+		err = rows.Scan(&name)
+		return name, err
+	}) {
+		assert.NoError(t, err)
+		names = append(names, name)
+	}
+	assert.Equal(t, []string{"ann", "bob"}, names)
+
+	// A scan failure is yielded once, and ends the iteration.
+	boom := errors.New("boom")
+	var errs []error
+	for _, err := range RowsFunc(context.Background(), b, func(*sql.Rows) (string, error) {
+		return "", boom
+	}) {
+		errs = append(errs, err)
+	}
+	assert.Equal(t, []error{boom}, errs)
+	assert.Equal(t, 0, fake.openRows)
+}
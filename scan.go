@@ -0,0 +1,287 @@
+package squirrel2
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// contextQueryer is implemented by the builders that run their query with the
+// Runner set by RunWith, e.g. the one returned by Select.
+type contextQueryer interface {
+	QueryContext(ctx context.Context) (*sql.Rows, error)
+}
+
+// ScanAll runs the query of q, usually a builder returned by Select, and scans
+// every row of its result into a T.
+//
+// T may be a struct, a pointer to one, or any type database/sql can scan a
+// single column into. Struct fields are matched to result columns by their db
+// tag (e.g. `db:"created_at"`); fields without one, or tagged `db:"-"`, are
+// ignored. Fields of untagged embedded structs are matched as if they belonged
+// to the outer struct. Use pointer or sql.Scanner fields (e.g. sql.NullString)
+// for nullable columns.
+//
+// ScanAll fails if a result column has no matching field, or if a tagged field
+// has no matching column.
+func ScanAll[T any](ctx context.Context, q contextQueryer) ([]T, error) {
+	ctx, timeout := withRowsTimeout(ctx)
+	rows, err := q.QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	values, err := scanRows[T](rows, false)
+	return values, timeout.end(err)
+}
+
+// ScanAllWith runs the SQL returned by s with db and scans every row of its
+// result into a T. See ScanAll.
+func ScanAllWith[T any](ctx context.Context, db QueryerContext, s Sqlizer) ([]T, error) {
+	rows, err := QueryContextWith(ctx, db, s)
+	if err != nil {
+		return nil, err
+	}
+	return scanRows[T](rows, false)
+}
+
+// ScanOne runs the query of q and scans the first row of its result into a T,
+// as ScanAll does. It returns sql.ErrNoRows if the result is empty.
+func ScanOne[T any](ctx context.Context, q contextQueryer) (T, error) {
+	ctx, timeout := withRowsTimeout(ctx)
+	rows, err := q.QueryContext(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	value, err := scanOne[T](rows)
+	return value, timeout.end(err)
+}
+
+// ScanOneWith runs the SQL returned by s with db and scans the first row of
+// its result into a T. See ScanOne.
+func ScanOneWith[T any](ctx context.Context, db QueryerContext, s Sqlizer) (T, error) {
+	rows, err := QueryContextWith(ctx, db, s)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return scanOne[T](rows)
+}
+
+func scanOne[T any](rows *sql.Rows) (T, error) {
+	var zero T
+	values, err := scanRows[T](rows, true)
+	if err != nil {
+		return zero, err
+	}
+	if len(values) == 0 {
+		return zero, sql.ErrNoRows
+	}
+	return values[0], nil
+}
+
+// scanRows scans rows into Ts and closes them. If first is set, it stops
+// after the first row.
+func scanRows[T any](rows *sql.Rows, first bool) (values []T, err error) {
+	defer func() {
+		if closeErr := rows.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	dest, err := newRowScanner(typ, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var value T
+		if err = rows.Scan(dest.targets(reflect.ValueOf(&value).Elem())...); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if first {
+			break
+		}
+	}
+	return values, rows.Err()
+}
+
+// rowScanner maps the columns of a result to the fields of a type.
+type rowScanner struct {
+	// paths holds the index path of the field of each column, or nil if the
+	// type is scanned as a whole from a single column.
+	paths [][]int
+	// ptr is set if the type is a pointer to the mapped struct.
+	ptr bool
+}
+
+func newRowScanner(typ reflect.Type, columns []string) (*rowScanner, error) {
+	structType, ptr := typ, false
+	if structType.Kind() == reflect.Ptr {
+		structType, ptr = structType.Elem(), true
+	}
+	if !isMappedStruct(structType) {
+		if len(columns) != 1 {
+			return nil, fmt.Errorf("cannot scan %d columns into %s", len(columns), typ)
+		}
+		return &rowScanner{}, nil
+	}
+
+	fields := structFieldsOf(structType)
+	s := &rowScanner{paths: make([][]int, len(columns)), ptr: ptr}
+	seen := make(map[string]bool, len(columns))
+	for i, column := range columns {
+		path, ok := fields.paths[column]
+		if !ok {
+			return nil, fmt.Errorf("column %q has no matching db tag in %s", column, structType)
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("column %q appears more than once in the result", column)
+		}
+		seen[column] = true
+		s.paths[i] = path
+	}
+	if len(seen) < len(fields.names) {
+		var missing []string
+		for _, column := range fields.names {
+			if !seen[column] {
+				missing = append(missing, column)
+			}
+		}
+		return nil, fmt.Errorf("%s has no column for db tags %q", structType, missing)
+	}
+	return s, nil
+}
+
+// targets returns the Scan destinations for the columns of a row scanned into
+// v.
+func (s *rowScanner) targets(v reflect.Value) []interface{} {
+	if s.paths == nil {
+		return []interface{}{v.Addr().Interface()}
+	}
+	if s.ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	targets := make([]interface{}, len(s.paths))
+	for i, path := range s.paths {
+		targets[i] = fieldByIndexAlloc(v, path).Addr().Interface()
+	}
+	return targets
+}
+
+// fieldByIndexAlloc is like reflect.Value.FieldByIndex, but it allocates the
+// nil embedded struct pointers on the path.
+func fieldByIndexAlloc(v reflect.Value, path []int) reflect.Value {
+	for i, idx := range path {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(idx)
+	}
+	return v
+}
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// isMappedStruct reports whether t is a struct whose fields are scanned
+// separately, rather than a value such as a time.Time or a sql.Scanner.
+func isMappedStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PtrTo(t).Implements(scannerType)
+}
+
+// structFields is the mapping of a struct type to result columns.
+type structFields struct {
+	// names holds the db tags of the fields, in field order.
+	names []string
+	// paths holds the index path of the field matching each db tag.
+	paths map[string][]int
+}
+
+// structFieldsCache caches the result of structFieldsOf by reflect.Type.
+var structFieldsCache sync.Map
+
+// structFieldsOf returns the fields of t that have a db tag. As with
+// encoding/json, a field shadows the fields of the same name that are nested
+// deeper in embedded structs, and fields of the same name at the same depth
+// are ambiguous, so all of them are dropped.
+func structFieldsOf(t reflect.Type) *structFields {
+	if fields, ok := structFieldsCache.Load(t); ok {
+		return fields.(*structFields)
+	}
+
+	var candidates []taggedField
+	collectTaggedFields(t, nil, &candidates)
+	chosen := make(map[string]int, len(candidates))
+	ambiguous := make(map[string]bool)
+	for i, f := range candidates {
+		j, ok := chosen[f.name]
+		switch {
+		case !ok || len(f.path) < len(candidates[j].path):
+			chosen[f.name] = i
+			ambiguous[f.name] = false
+		case len(f.path) == len(candidates[j].path):
+			ambiguous[f.name] = true
+		}
+	}
+	fields := &structFields{paths: make(map[string][]int, len(chosen))}
+	for i, f := range candidates {
+		if chosen[f.name] == i && !ambiguous[f.name] {
+			fields.names = append(fields.names, f.name)
+			fields.paths[f.name] = f.path
+		}
+	}
+
+	structFieldsCache.Store(t, fields)
+	return fields
+}
+
+type taggedField struct {
+	name string
+	path []int
+}
+
+// collectTaggedFields appends the tagged fields of t, and of its untagged
+// embedded structs, to fields.
+func collectTaggedFields(t reflect.Type, index []int, fields *[]taggedField) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		path := append(append([]int(nil), index...), i)
+		name, _, _ := strings.Cut(f.Tag.Get("db"), ",")
+
+		if name == "" && f.Anonymous {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				if !f.IsExported() {
+					// It can't be allocated through reflection.
+					continue
+				}
+				ft = ft.Elem()
+			}
+			if isMappedStruct(ft) {
+				collectTaggedFields(ft, path, fields)
+			}
+			continue
+		}
+		if name == "" || name == "-" || !f.IsExported() {
+			continue
+		}
+		*fields = append(*fields, taggedField{name: name, path: path})
+	}
+}
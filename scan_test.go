@@ -0,0 +1,178 @@
+package squirrel2
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type scanAudit struct {
+	CreatedAt time.Time  `db:"created_at"`
+	DeletedAt *time.Time `db:"deleted_at"`
+}
+
+type scanUser struct {
+	ID    int64          `db:"id"`
+	Name  string         `db:"name"`
+	Email sql.NullString `db:"email"`
+	Age   *int           `db:"age,omitempty"`
+	Note  string         `db:"-"`
+	Other string
+	scanAudit
+}
+
+var scanUserColumns = []string{"id", "name", "email", "age", "created_at", "deleted_at"}
+
+func TestScanAll(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	db, fake := newFakeDB(fakeResult{
+		columns: scanUserColumns,
+		rows: [][]driver.Value{
+			{int64(1), "ann", "ann@example.com", int64(30), now, nil},
+			{int64(2), "bob", nil, nil, now, now},
+		},
+	})
+
+	b := Select("*").From("users").Where(Eq{"active": true}).
+		PlaceholderFormat(Dollar).RunWith(db)
+	users, err := ScanAll[scanUser](context.Background(), b)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM users WHERE active = $1", fake.lastSql)
+	assert.Equal(t, 0, fake.openRows)
+
+	age := 30
+	expected := []scanUser{
+		{
+			ID: 1, Name: "ann", Email: sql.NullString{String: "ann@example.com", Valid: true}, Age: &age,
+			scanAudit: scanAudit{CreatedAt: now},
+		},
+		{ID: 2, Name: "bob", scanAudit: scanAudit{CreatedAt: now, DeletedAt: &now}},
+	}
+	assert.Equal(t, expected, users)
+
+	ptrs, err := ScanAll[*scanUser](context.Background(), b)
+	assert.NoError(t, err)
+	if assert.Len(t, ptrs, 2) {
+		assert.Equal(t, expected[1], *ptrs[1])
+	}
+}
+
+func TestScanAllEmbeddedPointer(t *testing.T) {
+	type Base struct {
+		ID int64 `db:"id"`
+	}
+	type Item struct {
+		*Base
+		Name string `db:"name"`
+		Code string `db:"code"`
+	}
+	db, _ := newFakeDB(fakeResult{
+		columns: []string{"id", "name", "code"},
+		rows:    [][]driver.Value{{int64(7), "seven", "x"}},
+	})
+
+	items, err := ScanAllWith[Item](context.Background(), db, Select("*").From("items"))
+	assert.NoError(t, err)
+	assert.Equal(t, []Item{{Base: &Base{ID: 7}, Name: "seven", Code: "x"}}, items)
+}
+
+func TestScanAllShadowedField(t *testing.T) {
+	type Base struct {
+		Name string `db:"name"`
+	}
+	type Item struct {
+		Base
+		Name string `db:"name"`
+	}
+	db, _ := newFakeDB(fakeResult{
+		columns: []string{"name"},
+		rows:    [][]driver.Value{{"outer"}},
+	})
+
+	items, err := ScanAllWith[Item](context.Background(), db, Select("name").From("items"))
+	assert.NoError(t, err)
+	assert.Equal(t, []Item{{Name: "outer"}}, items)
+}
+
+func TestScanAllAmbiguousField(t *testing.T) {
+	type A struct {
+		Name string `db:"name"`
+	}
+	type B struct {
+		Name string `db:"name"`
+	}
+	type Item struct {
+		A
+		B
+	}
+	db, _ := newFakeDB(fakeResult{
+		columns: []string{"name"},
+		rows:    [][]driver.Value{{"ann"}},
+	})
+
+	_, err := ScanAllWith[Item](context.Background(), db, Select("name").From("items"))
+	assert.EqualError(t, err, `column "name" has no matching db tag in squirrel2.Item`)
+}
+
+func TestScanAllScalar(t *testing.T) {
+	db, _ := newFakeDB(fakeResult{
+		columns: []string{"name"},
+		rows:    [][]driver.Value{{"ann"}, {nil}},
+	})
+
+	names, err := ScanAllWith[*string](context.Background(), db, Select("name").From("users"))
+	assert.NoError(t, err)
+	if assert.Len(t, names, 2) {
+		assert.Equal(t, "ann", *names[0])
+		assert.Nil(t, names[1])
+	}
+
+	_, err = ScanAllWith[string](context.Background(), db, Select("name").From("users"))
+	assert.Error(t, err)
+}
+
+func TestScanAllColumnErrors(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{columns: append(scanUserColumns, "extra")})
+	b := Select("*").From("users").RunWith(db)
+
+	_, err := ScanAll[scanUser](context.Background(), b)
+	assert.EqualError(t, err, `column "extra" has no matching db tag in squirrel2.scanUser`)
+	assert.Equal(t, 0, fake.openRows)
+
+	fake.result.columns = []string{"id", "name", "email"}
+	_, err = ScanAll[scanUser](context.Background(), b)
+	assert.EqualError(t, err, `squirrel2.scanUser has no column for db tags ["age" "created_at" "deleted_at"]`)
+
+	fake.result.columns = []string{"id", "id"}
+	_, err = ScanAll[int64](context.Background(), b)
+	assert.EqualError(t, err, "cannot scan 2 columns into int64")
+}
+
+func TestScanOne(t *testing.T) {
+	db, _ := newFakeDB(fakeResult{
+		columns: []string{"count"},
+		rows:    [][]driver.Value{{int64(3)}, {int64(4)}},
+	})
+	b := Select("COUNT(*) AS count").From("users").RunWith(db)
+
+	count, err := ScanOne[int](context.Background(), b)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	db, _ = newFakeDB(fakeResult{columns: scanUserColumns})
+	user, err := ScanOneWith[*scanUser](context.Background(), db, Select("*").From("users"))
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, user)
+}
+
+func TestScanAllRunnerNotSet(t *testing.T) {
+	_, err := ScanAll[scanUser](context.Background(), Select("*").From("users"))
+	assert.Equal(t, ErrRunnerNotSet, err)
+
+	_, err = ScanOneWith[scanUser](context.Background(), &DBStub{}, Select())
+	assert.Error(t, err)
+}
@@ -2,14 +2,19 @@ package squirrel2
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
 	"strconv"
+	"time"
 )
 
 type selectData struct {
 	PlaceholderFormat PlaceholderFormat
 	RunWith           BaseRunner
+	Timeout           time.Duration
+	Tags              sqlTags
+	Primary           bool
 	Prefixes          []Sqlizer
 	Options           []safeString
 	Columns           []Sqlizer
@@ -28,6 +33,9 @@ func (d *selectData) Exec() (sql.Result, error) {
 	if d.RunWith == nil {
 		return nil, ErrRunnerNotSet
 	}
+	if d.Timeout > 0 {
+		return d.ExecContext(context.Background())
+	}
 	return ExecWith(d.RunWith, d)
 }
 
@@ -35,6 +43,12 @@ func (d *selectData) Query() (*sql.Rows, error) {
 	if d.RunWith == nil {
 		return nil, ErrRunnerNotSet
 	}
+	if d.Timeout > 0 {
+		return d.QueryContext(context.Background())
+	}
+	if _, ok := d.RunWith.(QueryerContext); ok && d.Primary {
+		return d.QueryContext(context.Background())
+	}
 	return QueryWith(d.RunWith, d)
 }
 
@@ -42,6 +56,12 @@ func (d *selectData) QueryRow() RowScanner {
 	if d.RunWith == nil {
 		return &Row{err: ErrRunnerNotSet}
 	}
+	if d.Timeout > 0 {
+		return d.QueryRowContext(context.Background())
+	}
+	if _, ok := d.RunWith.(QueryRowerContext); ok && d.Primary {
+		return d.QueryRowContext(context.Background())
+	}
 	queryRower, ok := d.RunWith.(QueryRower)
 	if !ok {
 		return &Row{err: ErrRunnerNotQueryRunner}
@@ -55,7 +75,7 @@ func (d *selectData) ToSql() (sqlStr string, args []interface{}, err error) {
 		return
 	}
 
-	sqlStr, err = d.PlaceholderFormat.ReplacePlaceholders(sqlStr)
+	sqlStr, args, err = replacePlaceholders(d.PlaceholderFormat, sqlStr, args)
 	return
 }
 
@@ -161,7 +181,8 @@ func (d *selectData) toSqlRaw() (sqlStr string, args []interface{}, err error) {
 		}
 	}
 
-	sqlStr = sql.String()
+	// The tags go with the statement, even when it is nested in another.
+	sqlStr = d.Tags.apply(sql.String())
 	return
 }
 
@@ -177,6 +198,8 @@ func SelectBuilder(b statementBuilderType) selectBuilder {
 		data: selectData{
 			PlaceholderFormat: b.placeholderFormat,
 			RunWith:           b.runWith,
+			Timeout:           b.timeout,
+			Tags:              b.tags,
 			WhereParts:        b.whereParts,
 			Prefixes:          make([]Sqlizer, 0),
 			Options:           make([]safeString, 0),
@@ -216,6 +239,45 @@ func (b selectBuilder) RunWith(runner BaseRunner) selectBuilder {
 	return b
 }
 
+// Timeout sets the time the statement may run for. It applies to both the
+// Context and the non-Context methods (e.g. QueryContext and Query), which then
+// require a Runner supporting contexts; it also bounds the reading of the rows
+// of a Query. Statements that time out fail with a *TimeoutError.
+//
+// ScanAll, ScanOne, Rows and RowsFunc end the timeout once they're done with
+// the rows, and report reading them past it as a *TimeoutError. The rows
+// returned by QueryContext and Query can't do that, as *sql.Rows can't tell
+// when they're closed: their timeout lasts until it expires, and reading them
+// past it fails with context.DeadlineExceeded. Each of these Queries ties up a
+// timer and a context for the whole timeout, even once its rows are closed, so
+// prefer the scanning functions with long timeouts and frequent queries.
+func (b selectBuilder) Timeout(d time.Duration) selectBuilder {
+	b.data.Timeout = d
+	return b
+}
+
+// Tag adds a tag to the comment of the statement, in the sqlcommenter format
+// (e.g. /*route='%2Fusers',service='api'*/), so that the statement can be
+// traced back to its code in the logs of the database. Keys and values are
+// URL-encoded. The comment also holds the tags of the context of the Context
+// methods (see WithTags). A statement nested in another, e.g. a subquery or a
+// statement of a Batch, keeps its own comment.
+//
+// Ex:
+//
+//	Select("*").From("users").Tag("service", "api").Tag("route", "/users")
+func (b selectBuilder) Tag(key, value string) selectBuilder {
+	b.data.Tags = b.data.Tags.with(key, value)
+	return b
+}
+
+// TagPosition sets where the comment holding the tags goes: at the end of the
+// statement (the default) or at its start.
+func (b selectBuilder) TagPosition(p TagPosition) selectBuilder {
+	b.data.Tags.position = p
+	return b
+}
+
 // Exec builds and Execs the query with the Runner set by RunWith.
 func (b selectBuilder) Exec() (sql.Result, error) {
 	return b.data.Exec()
@@ -236,6 +298,13 @@ func (b selectBuilder) Scan(dest ...interface{}) error {
 	return b.QueryRow().Scan(dest...)
 }
 
+// Primary hints a Router to run the query with its primary, e.g. to read
+// data that must be up to date. See WithPrimary.
+func (b selectBuilder) Primary() selectBuilder {
+	b.data.Primary = true
+	return b
+}
+
 // SQL methods
 
 // ToSql builds the query into a SQL string and bound args.
@@ -313,8 +382,6 @@ func (b selectBuilder) From(from safeString) selectBuilder {
 
 // FromSelect sets a subquery into the FROM clause of the query.
 func (b selectBuilder) FromSelect(from selectBuilder, alias safeString) selectBuilder {
-	// Prevent misnumbered parameters in nested selects (#183).
-	from = from.PlaceholderFormat(Question)
 	b.data.From = Alias(from, alias)
 	return b
 }
@@ -327,26 +394,31 @@ func (b selectBuilder) JoinClause(expr Sqlizer) selectBuilder {
 
 // Join adds a JOIN clause to the query.
 func (b selectBuilder) Join(join safeString, rest ...interface{}) selectBuilder {
+	//squirrelvet:allow join is a safeString
 	return b.JoinClause(Expr("JOIN "+join, rest...))
 }
 
 // LeftJoin adds a LEFT JOIN clause to the query.
 func (b selectBuilder) LeftJoin(join safeString, rest ...interface{}) selectBuilder {
+	//squirrelvet:allow join is a safeString
 	return b.JoinClause(Expr("LEFT JOIN "+join, rest...))
 }
 
 // RightJoin adds a RIGHT JOIN clause to the query.
 func (b selectBuilder) RightJoin(join safeString, rest ...interface{}) selectBuilder {
+	//squirrelvet:allow join is a safeString
 	return b.JoinClause(Expr("RIGHT JOIN "+join, rest...))
 }
 
 // InnerJoin adds a INNER JOIN clause to the query.
 func (b selectBuilder) InnerJoin(join safeString, rest ...interface{}) selectBuilder {
+	//squirrelvet:allow join is a safeString
 	return b.JoinClause(Expr("INNER JOIN "+join, rest...))
 }
 
 // CrossJoin adds a CROSS JOIN clause to the query.
 func (b selectBuilder) CrossJoin(join safeString, rest ...interface{}) selectBuilder {
+	//squirrelvet:allow join is a safeString
 	return b.JoinClause(Expr("CROSS JOIN "+join, rest...))
 }
 
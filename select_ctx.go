@@ -16,6 +16,10 @@ func (d *selectData) ExecContext(ctx context.Context) (sql.Result, error) {
 	if !ok {
 		return nil, ErrNoContextSupport
 	}
+	d = d.withContextTags(ctx)
+	if d.Timeout > 0 {
+		return execContextTimeout(ctx, d.Timeout, ctxRunner, d)
+	}
 	return ExecContextWith(ctx, ctxRunner, d)
 }
 
@@ -27,6 +31,13 @@ func (d *selectData) QueryContext(ctx context.Context) (*sql.Rows, error) {
 	if !ok {
 		return nil, ErrNoContextSupport
 	}
+	if d.Primary {
+		ctx = WithPrimary(ctx)
+	}
+	d = d.withContextTags(ctx)
+	if d.Timeout > 0 {
+		return queryContextTimeout(ctx, d.Timeout, ctxRunner, d)
+	}
 	return QueryContextWith(ctx, ctxRunner, d)
 }
 
@@ -41,6 +52,13 @@ func (d *selectData) QueryRowContext(ctx context.Context) RowScanner {
 		}
 		return &Row{err: ErrNoContextSupport}
 	}
+	if d.Primary {
+		ctx = WithPrimary(ctx)
+	}
+	d = d.withContextTags(ctx)
+	if d.Timeout > 0 {
+		return queryRowContextTimeout(ctx, d.Timeout, queryRower, d)
+	}
 	return QueryRowContextWith(ctx, queryRower, d)
 }
 
@@ -63,3 +81,14 @@ func (b selectBuilder) QueryRowContext(ctx context.Context) RowScanner {
 func (b selectBuilder) ScanContext(ctx context.Context, dest ...interface{}) error {
 	return b.QueryRowContext(ctx).Scan(dest...)
 }
+
+// withContextTags returns d, or a copy of it that also has the tags of ctx.
+func (d *selectData) withContextTags(ctx context.Context) *selectData {
+	tags := ContextTags(ctx)
+	if len(tags) == 0 {
+		return d
+	}
+	tagged := *d
+	tagged.Tags = d.Tags.withDefaults(tags)
+	return &tagged
+}
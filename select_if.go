@@ -92,6 +92,7 @@ func (b selectBuilder) JoinClauseIf(expr Sqlizer, include bool) selectBuilder {
 // JoinIf adds a JOIN clause to the query if include is true.
 func (b selectBuilder) JoinIf(join safeString, include bool, rest ...interface{}) selectBuilder {
 	if include {
+		//squirrelvet:allow join is a safeString
 		return b.JoinClause(Expr("JOIN "+join, rest...))
 	}
 	return b
@@ -100,6 +101,7 @@ func (b selectBuilder) JoinIf(join safeString, include bool, rest ...interface{}
 // LeftJoinIf adds a LEFT JOIN clause to the query if include is true.
 func (b selectBuilder) LeftJoinIf(join safeString, include bool, rest ...interface{}) selectBuilder {
 	if include {
+		//squirrelvet:allow join is a safeString
 		return b.JoinClause(Expr("LEFT JOIN "+join, rest...))
 	}
 	return b
@@ -108,6 +110,7 @@ func (b selectBuilder) LeftJoinIf(join safeString, include bool, rest ...interfa
 // RightJoinIf adds a RIGHT JOIN clause to the query if include is true.
 func (b selectBuilder) RightJoinIf(join safeString, include bool, rest ...interface{}) selectBuilder {
 	if include {
+		//squirrelvet:allow join is a safeString
 		return b.JoinClause(Expr("RIGHT JOIN "+join, rest...))
 	}
 	return b
@@ -116,6 +119,7 @@ func (b selectBuilder) RightJoinIf(join safeString, include bool, rest ...interf
 // InnerJoinIf adds a INNER JOIN clause to the query if include is true.
 func (b selectBuilder) InnerJoinIf(join safeString, include bool, rest ...interface{}) selectBuilder {
 	if include {
+		//squirrelvet:allow join is a safeString
 		return b.JoinClause(Expr("INNER JOIN "+join, rest...))
 	}
 	return b
@@ -124,6 +128,7 @@ func (b selectBuilder) InnerJoinIf(join safeString, include bool, rest ...interf
 // CrossJoinIf adds a CROSS JOIN clause to the query if include is true.
 func (b selectBuilder) CrossJoinIf(join safeString, include bool, rest ...interface{}) selectBuilder {
 	if include {
+		//squirrelvet:allow join is a safeString
 		return b.JoinClause(Expr("CROSS JOIN "+join, rest...))
 	}
 	return b
@@ -150,3 +150,23 @@ func TestSelectBuilder(t *testing.T) {
 		assert.Equal(t, []interface{}{true}, args)
 	})
 }
+
+func TestSelectBuilderNestedDollar(t *testing.T) {
+	subquery := Select("id").From("posts").Where(Eq{"published": true}).PlaceholderFormat(Dollar)
+
+	b := Select("user_id").
+		Column(Alias(subquery.Column(Expr("? AS one", 1)), "sub")).
+		FromSelect(subquery, "p").
+		Where(Expr("user_id IN (?)", subquery)).
+		Where(ConcatExpr(Expr("score > ?", 5), Expr(" AND rank < ?", 3))).
+		PlaceholderFormat(Dollar)
+
+	sql, args, err := b.ToSql()
+	assert.NoError(t, err)
+
+	expectedSql := "SELECT user_id, (SELECT id, $1 AS one FROM posts WHERE published = $2) AS sub " +
+		"FROM (SELECT id FROM posts WHERE published = $3) AS p " +
+		"WHERE user_id IN (SELECT id FROM posts WHERE published = $4) AND score > $5 AND rank < $6"
+	assert.Equal(t, expectedSql, sql)
+	assert.Equal(t, []interface{}{1, true, true, true, 5, 3}, args)
+}
@@ -8,7 +8,6 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"strings"
 )
 
 // Sqlizer is the interface that wraps the ToSql method.
@@ -20,7 +19,10 @@ type Sqlizer interface {
 }
 
 // rawSqlizer is expected to do what Sqlizer does, but without finalizing placeholders.
-// This is useful for nested queries.
+// This is useful for nested queries: every builder and composite expression
+// renders its parts raw, so that only the outermost statement replaces
+// placeholders. Sqlizers whose ToSql never finalizes placeholders, like Eq,
+// don't need to implement it.
 type rawSqlizer interface {
 	toSqlRaw() (string, []interface{}, error)
 }
@@ -119,7 +121,8 @@ func QueryRowWith(db QueryRower, s Sqlizer) RowScanner {
 // IMPORTANT: As its name suggests, this function should only be used for
 // debugging. While the string result *might* be valid SQL, this function does
 // not try very hard to ensure it. Additionally, executing the output of this
-// function with any untrusted user input is certainly insecure.
+// function with any untrusted user input is certainly insecure. InlineSql
+// renders statements with args escaped for a given Dialect.
 func DebugSqlizer(s Sqlizer) string {
 	sql, args, err := s.ToSql()
 	if err != nil {
@@ -133,40 +136,48 @@ func DebugSqlizer(s Sqlizer) string {
 	} else {
 		placeholder = downCast.debugPlaceholder()
 	}
-	// TODO: dedupe this with placeholder.go
+
+	lexer := sqlLexer{jsonbOperators: placeholder == "$"}
+	if placeholder != "?" {
+		lexer.positional = placeholder
+	}
+
 	buf := &bytes.Buffer{}
 	i := 0
-	for {
-		p := strings.Index(sql, placeholder)
-		if p == -1 {
-			break
-		}
-		if len(sql[p:]) > 1 && sql[p:p+2] == "??" { // escape ?? => ?
-			buf.WriteString(sql[:p])
-			buf.WriteString("?")
-			if len(sql[p:]) == 1 {
-				break
+	err = lexer.scan(sql, func(tok sqlToken) error {
+		arg := i
+		switch tok.kind {
+		case sqlPlaceholder:
+			if placeholder != "?" {
+				buf.WriteString(tok.text)
+				return nil
 			}
-			sql = sql[p+2:]
-		} else {
-			if i+1 > len(args) {
-				return fmt.Sprintf(
-					"[DebugSqlizer error: too many placeholders in %#v for %d args]",
-					sql, len(args))
-			}
-			buf.WriteString(sql[:p])
-			fmt.Fprintf(buf, "'%v'", args[i])
-			// advance our sql string "cursor" beyond the arg we placed
-			sql = sql[p+1:]
 			i++
+		case sqlPositional:
+			arg = tok.index - 1
+			if tok.index > i {
+				i = tok.index
+			}
+		case sqlEscapedPlaceholder:
+			buf.WriteString("?")
+			return nil
+		default:
+			buf.WriteString(tok.text)
+			return nil
 		}
+		if arg < 0 || arg >= len(args) {
+			return fmt.Errorf("too many placeholders in %#v for %d args", sql, len(args))
+		}
+		fmt.Fprintf(buf, "'%v'", args[arg])
+		return nil
+	})
+	if err != nil {
+		return fmt.Sprintf("[DebugSqlizer error: %s]", err)
 	}
 	if i < len(args) {
 		return fmt.Sprintf(
 			"[DebugSqlizer error: not enough placeholders in %#v for %d args]",
 			sql, len(args))
 	}
-	// "append" any remaning sql that won't need interpolating
-	buf.WriteString(sql)
 	return buf.String()
 }
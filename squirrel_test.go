@@ -185,8 +185,8 @@ func TestDebugSqlizerSelectQuestion(t *testing.T) {
 }
 
 func TestDebugSqlizer(t *testing.T) {
-	sqlizer := Expr("x = ? AND y = ? AND z = '??'", 1, "text")
-	expectedDebug := "x = '1' AND y = 'text' AND z = '?'"
+	sqlizer := Expr("x = ? AND y = ? AND z = '?' AND w ?? 'k'", 1, "text")
+	expectedDebug := "x = '1' AND y = 'text' AND z = '?' AND w ? 'k'"
 	assert.Equal(t, expectedDebug, DebugSqlizer(sqlizer))
 }
 
@@ -200,3 +200,32 @@ func TestDebugSqlizerErrors(t *testing.T) {
 	errorMsg = DebugSqlizer(Lt{"x": nil}) // Cannot use nil values with Lt
 	assert.True(t, strings.HasPrefix(errorMsg, "[ToSql error: "))
 }
+
+type dollarSqlizer struct {
+	selectBuilder
+	dollarFormat
+}
+
+func TestDebugSqlizerPositional(t *testing.T) {
+	args := make([]interface{}, 11)
+	for i := range args {
+		args[i] = i + 1
+	}
+	sqlizer := dollarSqlizer{
+		selectBuilder: Select("*").From("t").
+			Where(Expr("a IN ("+Placeholders(11)+") AND b = '$1'", args...)). //squirrelvet:allow Placeholders is constant SQL
+			PlaceholderFormat(Dollar),
+	}
+	expectedDebug := "SELECT * FROM t WHERE a IN ('1','2','3','4','5','6','7','8','9','10','11') AND b = '$1'"
+	assert.Equal(t, expectedDebug, DebugSqlizer(sqlizer))
+}
+
+func TestBuilderPlaceholderArgMismatch(t *testing.T) {
+	_, _, err := Select("*").From("t").Where(Expr("a = ? AND b = ?", 1)).ToSql()
+	assert.Error(t, err)
+
+	sql, args, err := Select("*").From("t").Where(Expr("a = ? AND b = 'x?'", 1)).PlaceholderFormat(Dollar).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE a = $1 AND b = 'x?'", sql)
+	assert.Equal(t, []interface{}{1}, args)
+}
@@ -0,0 +1,60 @@
+package squirreltest
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+)
+
+// The private driver of a Mock turns the canned rows of its expectations into
+// *sql.Rows, which can't be built otherwise. Queries are run with the id the
+// rows were registered with instead of their SQL.
+
+type connector struct{ mock *Mock }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return conn{c.mock}, nil }
+func (c connector) Driver() driver.Driver                        { return mockDriver{} }
+
+type mockDriver struct{}
+
+func (mockDriver) Open(string) (driver.Conn, error) {
+	return nil, fmt.Errorf("squirreltest: use New to create a Mock")
+}
+
+type conn struct{ mock *Mock }
+
+func (c conn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("squirreltest: cannot prepare %q", query)
+}
+
+func (c conn) Close() error { return nil }
+
+func (c conn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("squirreltest: transactions are not supported")
+}
+
+func (c conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result, ok := c.mock.takeResult(query)
+	if !ok {
+		return nil, fmt.Errorf("squirreltest: no rows registered for %q", query)
+	}
+	return &rows{result: result}, nil
+}
+
+type rows struct {
+	result *rowsResult
+	next   int
+}
+
+func (r *rows) Columns() []string { return r.result.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.next])
+	r.next++
+	return nil
+}
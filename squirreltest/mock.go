@@ -0,0 +1,392 @@
+// Package squirreltest provides a mock squirrel2.RunnerContext for the unit
+// tests of code running statements built with squirrel2.
+//
+// Ex:
+//
+//	mock := squirreltest.New()
+//	mock.ExpectQuery("SELECT name FROM users WHERE id = ?").
+//		WithArgs(42).
+//		WillReturnRows([]string{"name"}, []interface{}{"ann"})
+//
+//	name, err := repo.UserName(ctx, mock, 42)
+//
+//	mock.AssertExpectationsMet(t)
+package squirreltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sync"
+
+	sq "github.com/cauanvital/squirrel2"
+)
+
+// TestingT is the subset of testing.TB used by Mock.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Mock is a squirrel2.RunnerContext checking the statements it's asked to run
+// against a list of expectations, and answering them with canned results.
+//
+// By default, statements must be run in the order they're expected; see
+// InAnyOrder. Statements that don't match an expectation fail with an error,
+// and are reported by AssertExpectationsMet.
+type Mock struct {
+	mu           sync.Mutex
+	expectations []*Expectation
+	unordered    bool
+	unexpected   []string
+
+	db *sql.DB
+	// results holds the canned rows of the queries being run, by the id
+	// that is passed to the private driver instead of their SQL.
+	results map[string]*rowsResult
+	nextID  int
+}
+
+var _ sq.RunnerContext = (*Mock)(nil)
+
+// New returns a Mock without expectations.
+func New() *Mock {
+	m := &Mock{results: make(map[string]*rowsResult)}
+	m.db = sql.OpenDB(connector{m})
+	return m
+}
+
+// InAnyOrder lets the statements run in a different order than they're
+// expected. It returns m.
+func (m *Mock) InAnyOrder() *Mock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unordered = true
+	return m
+}
+
+// ExpectExec expects Exec or ExecContext to be called with sql.
+func (m *Mock) ExpectExec(sql string) *Expectation {
+	return m.expect(methodExec, exactSQL(sql))
+}
+
+// ExpectExecRegexp expects Exec or ExecContext to be called with SQL matching
+// pattern.
+func (m *Mock) ExpectExecRegexp(pattern string) *Expectation {
+	return m.expect(methodExec, regexpSQL{regexp.MustCompile(pattern)})
+}
+
+// ExpectQuery expects Query, QueryRow or their Context versions to be called
+// with sql.
+func (m *Mock) ExpectQuery(sql string) *Expectation {
+	return m.expect(methodQuery, exactSQL(sql))
+}
+
+// ExpectQueryRegexp expects Query, QueryRow or their Context versions to be
+// called with SQL matching pattern.
+func (m *Mock) ExpectQueryRegexp(pattern string) *Expectation {
+	return m.expect(methodQuery, regexpSQL{regexp.MustCompile(pattern)})
+}
+
+func (m *Mock) expect(method string, sql sqlMatcher) *Expectation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := &Expectation{method: method, sql: sql, result: result{}}
+	m.expectations = append(m.expectations, e)
+	return e
+}
+
+// AssertExpectationsMet reports, through t, the expectations that weren't met
+// and the statements that didn't match any expectation.
+func (m *Mock) AssertExpectationsMet(t TestingT) bool {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ok := true
+	for _, e := range m.expectations {
+		if !e.met {
+			t.Errorf("squirreltest: expectation not met: %s", e)
+			ok = false
+		}
+	}
+	for _, call := range m.unexpected {
+		t.Errorf("squirreltest: unexpected call: %s", call)
+		ok = false
+	}
+	return ok
+}
+
+const (
+	methodExec  = "Exec"
+	methodQuery = "Query"
+)
+
+// match finds the expectation of a call, marking it as met.
+func (m *Mock) match(method, query string, args []interface{}) (*Expectation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var mismatch string
+	for _, e := range m.expectations {
+		if e.met {
+			continue
+		}
+		if reason := e.mismatch(method, query, args); reason != "" {
+			if !m.unordered {
+				mismatch = fmt.Sprintf("; next expectation is %s: %s", e, reason)
+				break
+			}
+			continue
+		}
+		e.met = true
+		return e, nil
+	}
+	call := fmt.Sprintf("%s %q with args %v", method, query, args)
+	m.unexpected = append(m.unexpected, call)
+	return nil, fmt.Errorf("squirreltest: unexpected call: %s%s", call, mismatch)
+}
+
+// Exec runs query.
+func (m *Mock) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return m.ExecContext(context.Background(), query, args...)
+}
+
+// Query runs query.
+func (m *Mock) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return m.QueryContext(context.Background(), query, args...)
+}
+
+// QueryRow runs query.
+func (m *Mock) QueryRow(query string, args ...interface{}) sq.RowScanner {
+	return m.QueryRowContext(context.Background(), query, args...)
+}
+
+// ExecContext runs query.
+func (m *Mock) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	e, err := m.match(methodExec, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.result, nil
+}
+
+// QueryContext runs query.
+func (m *Mock) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	e, err := m.match(methodQuery, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	return m.db.QueryContext(ctx, m.register(e.rows))
+}
+
+// QueryRowContext runs query.
+func (m *Mock) QueryRowContext(ctx context.Context, query string, args ...interface{}) sq.RowScanner {
+	e, err := m.match(methodQuery, query, args)
+	if err == nil {
+		err = e.err
+	}
+	if err != nil {
+		return errRow{err}
+	}
+	return m.db.QueryRowContext(ctx, m.register(e.rows))
+}
+
+// register stores rows for the private driver, returning the id to query
+// them with.
+func (m *Mock) register(rows *rowsResult) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	id := fmt.Sprintf("squirreltest:%d", m.nextID)
+	if rows == nil {
+		rows = &rowsResult{}
+	}
+	m.results[id] = rows
+	return id
+}
+
+func (m *Mock) takeResult(id string) (*rowsResult, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rows, ok := m.results[id]
+	delete(m.results, id)
+	return rows, ok
+}
+
+type errRow struct{ err error }
+
+func (r errRow) Scan(...interface{}) error { return r.err }
+
+// Expectation is a statement expected by a Mock. Its methods return it, so
+// that they can be chained.
+type Expectation struct {
+	method string
+	sql    sqlMatcher
+	// args is nil until WithArgs is called, matching any args.
+	args []interface{}
+
+	result sql.Result
+	rows   *rowsResult
+	err    error
+	met    bool
+}
+
+// WithArgs expects the statement to be run with args. Each arg is either an
+// ArgMatcher or a value compared to the actual arg, after converting both as
+// database/sql does (so that int(1) matches int64(1)). Statements match any
+// args until WithArgs is called.
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	if args == nil {
+		args = []interface{}{}
+	}
+	e.args = args
+	return e
+}
+
+// WillReturnResult answers an Exec with a sql.Result. Without it, an Exec is
+// answered with a result of 0 for both.
+func (e *Expectation) WillReturnResult(lastInsertID, rowsAffected int64) *Expectation {
+	e.result = result{lastInsertID: lastInsertID, rowsAffected: rowsAffected}
+	return e
+}
+
+// WillReturnRows answers a Query with rows of values for columns. Values are
+// converted as database/sql does; it panics if one can't be.
+func (e *Expectation) WillReturnRows(columns []string, rows ...[]interface{}) *Expectation {
+	r := &rowsResult{columns: columns}
+	for _, row := range rows {
+		if len(row) != len(columns) {
+			panic(fmt.Sprintf("squirreltest: row %v doesn't have %d columns", row, len(columns)))
+		}
+		values := make([]driver.Value, len(row))
+		for i, v := range row {
+			value, err := driver.DefaultParameterConverter.ConvertValue(v)
+			if err != nil {
+				panic(fmt.Sprintf("squirreltest: cannot return %#v: %v", v, err))
+			}
+			values[i] = value
+		}
+		r.rows = append(r.rows, values)
+	}
+	e.rows = r
+	return e
+}
+
+// WillReturnError fails the statement with err.
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+func (e *Expectation) String() string {
+	if e.args == nil {
+		return fmt.Sprintf("%s %s", e.method, e.sql)
+	}
+	return fmt.Sprintf("%s %s with args %v", e.method, e.sql, e.args)
+}
+
+// mismatch returns why a call doesn't match e, or "" if it does.
+func (e *Expectation) mismatch(method, query string, args []interface{}) string {
+	if method != e.method {
+		return fmt.Sprintf("method is %s", method)
+	}
+	if !e.sql.match(query) {
+		return fmt.Sprintf("SQL is %q", query)
+	}
+	if e.args == nil {
+		return ""
+	}
+	if len(args) != len(e.args) {
+		return fmt.Sprintf("got %d args", len(args))
+	}
+	for i, expected := range e.args {
+		m, ok := expected.(ArgMatcher)
+		if !ok {
+			m = equalArg{expected}
+		}
+		if !m.Match(args[i]) {
+			return fmt.Sprintf("arg %d is %#v, expected %s", i+1, args[i], m)
+		}
+	}
+	return ""
+}
+
+type sqlMatcher interface {
+	match(query string) bool
+	String() string
+}
+
+type exactSQL string
+
+func (s exactSQL) match(query string) bool { return query == string(s) }
+func (s exactSQL) String() string          { return fmt.Sprintf("%q", string(s)) }
+
+type regexpSQL struct{ re *regexp.Regexp }
+
+func (s regexpSQL) match(query string) bool { return s.re.MatchString(query) }
+func (s regexpSQL) String() string          { return fmt.Sprintf("matching /%s/", s.re) }
+
+// ArgMatcher matches an arg of a statement.
+type ArgMatcher interface {
+	Match(arg interface{}) bool
+	String() string
+}
+
+// AnyArg returns an ArgMatcher matching any arg.
+func AnyArg() ArgMatcher {
+	return ArgFunc("any arg", func(interface{}) bool { return true })
+}
+
+// ArgFunc returns an ArgMatcher described by desc that matches the args for
+// which match returns true.
+func ArgFunc(desc string, match func(arg interface{}) bool) ArgMatcher {
+	return argFunc{desc: desc, match: match}
+}
+
+type argFunc struct {
+	desc  string
+	match func(arg interface{}) bool
+}
+
+func (a argFunc) Match(arg interface{}) bool { return a.match(arg) }
+func (a argFunc) String() string             { return a.desc }
+
+type equalArg struct{ value interface{} }
+
+func (a equalArg) Match(arg interface{}) bool {
+	if reflect.DeepEqual(a.value, arg) {
+		return true
+	}
+	expected, err := driver.DefaultParameterConverter.ConvertValue(a.value)
+	if err != nil {
+		return false
+	}
+	actual, err := driver.DefaultParameterConverter.ConvertValue(arg)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(expected, actual)
+}
+
+func (a equalArg) String() string { return fmt.Sprintf("%#v", a.value) }
+
+type result struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r result) LastInsertId() (int64, error) { return r.lastInsertID, nil }
+func (r result) RowsAffected() (int64, error) { return r.rowsAffected, nil }
+
+type rowsResult struct {
+	columns []string
+	rows    [][]driver.Value
+}
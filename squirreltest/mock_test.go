@@ -0,0 +1,166 @@
+package squirreltest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	sq "github.com/cauanvital/squirrel2"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeT records the failures reported by AssertExpectationsMet.
+type fakeT struct{ errors []string }
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestMockExec(t *testing.T) {
+	mock := New()
+	mock.ExpectExec("UPDATE users SET name = ? WHERE id = ?").
+		WithArgs("ann", 1).
+		WillReturnResult(0, 2)
+
+	res, err := sq.Update("users").Set("name", "ann").Where(sq.Eq{"id": int64(1)}).
+		RunWith(mock).ExecContext(context.Background())
+	assert.NoError(t, err)
+	n, _ := res.RowsAffected()
+	assert.Equal(t, int64(2), n)
+	mock.AssertExpectationsMet(t)
+}
+
+func TestMockExecWithoutResult(t *testing.T) {
+	mock := New()
+	mock.ExpectExec("DELETE FROM users")
+	mock.ExpectExec("DELETE FROM sessions")
+
+	res, err := sq.Delete("users").RunWith(mock).ExecContext(context.Background())
+	assert.NoError(t, err)
+	n, err := res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+
+	_, err = sq.Delete("sessions").RunWith(mock).ExecExpect(context.Background(), 1)
+	assert.ErrorIs(t, err, sq.ErrNoRowsAffected)
+	mock.AssertExpectationsMet(t)
+}
+
+func TestMockQuery(t *testing.T) {
+	mock := New()
+	mock.ExpectQueryRegexp(`^SELECT id, name FROM users`).
+		WillReturnRows([]string{"id", "name"}, []interface{}{1, "ann"}, []interface{}{2, "bob"})
+	mock.ExpectQuery("SELECT name FROM users WHERE id = ?").
+		WithArgs(AnyArg()).
+		WillReturnRows([]string{"name"})
+	mock.ExpectQuery("SELECT name FROM users WHERE id = ?").
+		WithArgs(ArgFunc("positive", func(arg interface{}) bool { return arg.(int) > 0 })).
+		WillReturnRows([]string{"name"}, []interface{}{"cid"})
+
+	rows, err := sq.Select("id", "name").From("users").OrderBy("id").RunWith(mock).Query()
+	if assert.NoError(t, err) {
+		var names []string
+		for rows.Next() {
+			var id int
+			var name string
+			assert.NoError(t, rows.Scan(&id, &name))
+			names = append(names, name)
+		}
+		assert.NoError(t, rows.Close())
+		assert.Equal(t, []string{"ann", "bob"}, names)
+	}
+
+	var name string
+	err = sq.Select("name").From("users").Where(sq.Expr("id = ?", 3)).RunWith(mock).Scan(&name)
+	assert.Equal(t, sql.ErrNoRows, err)
+	err = sq.Select("name").From("users").Where(sq.Expr("id = ?", 4)).RunWith(mock).
+		ScanContext(context.Background(), &name)
+	assert.NoError(t, err)
+	assert.Equal(t, "cid", name)
+	mock.AssertExpectationsMet(t)
+}
+
+func TestMockError(t *testing.T) {
+	mock := New()
+	boom := errors.New("boom")
+	mock.ExpectExec("DELETE FROM t").WillReturnError(boom)
+	mock.ExpectQuery("SELECT x FROM t").WillReturnError(boom)
+	mock.ExpectQuery("SELECT x FROM t").WillReturnError(boom)
+
+	_, err := mock.Exec("DELETE FROM t")
+	assert.Equal(t, boom, err)
+	_, err = mock.Query("SELECT x FROM t")
+	assert.Equal(t, boom, err)
+	var x int
+	assert.Equal(t, boom, mock.QueryRow("SELECT x FROM t").Scan(&x))
+	mock.AssertExpectationsMet(t)
+}
+
+func TestMockOrder(t *testing.T) {
+	mock := New()
+	mock.ExpectExec("DELETE FROM a")
+	mock.ExpectExec("DELETE FROM b")
+
+	_, err := mock.Exec("DELETE FROM b")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `unexpected call: Exec "DELETE FROM b"`)
+		assert.Contains(t, err.Error(), `next expectation is Exec "DELETE FROM a"`)
+	}
+
+	mock = New().InAnyOrder()
+	mock.ExpectExec("DELETE FROM a")
+	mock.ExpectExec("DELETE FROM b")
+	_, err = mock.Exec("DELETE FROM b")
+	assert.NoError(t, err)
+	_, err = mock.Exec("DELETE FROM a")
+	assert.NoError(t, err)
+	mock.AssertExpectationsMet(t)
+}
+
+func TestMockArgs(t *testing.T) {
+	mock := New()
+	mock.ExpectExec("DELETE FROM t WHERE id = ?").WithArgs(1)
+	mock.ExpectExec("DELETE FROM t").WithArgs()
+
+	_, err := mock.Exec("DELETE FROM t WHERE id = ?", 2)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "arg 1 is 2, expected 1")
+	}
+	_, err = mock.Exec("DELETE FROM t WHERE id = ?", uint8(1))
+	assert.NoError(t, err)
+	_, err = mock.Exec("DELETE FROM t", 1)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "got 1 args")
+	}
+}
+
+func TestAssertExpectationsMet(t *testing.T) {
+	mock := New()
+	mock.ExpectExec("DELETE FROM a").WithArgs(1)
+	mock.ExpectQueryRegexp("^SELECT")
+	_, _ = mock.Query("SELECT 1")
+	_, _ = mock.Exec("DELETE FROM b")
+
+	ft := &fakeT{}
+	assert.False(t, mock.AssertExpectationsMet(ft))
+	assert.Equal(t, []string{
+		`squirreltest: expectation not met: Exec "DELETE FROM a" with args [1]`,
+		"squirreltest: expectation not met: Query matching /^SELECT/",
+		`squirreltest: unexpected call: Query "SELECT 1" with args []`,
+		`squirreltest: unexpected call: Exec "DELETE FROM b" with args []`,
+	}, ft.errors)
+}
+
+func TestWillReturnRowsPanics(t *testing.T) {
+	mock := New()
+	assert.PanicsWithValue(t, "squirreltest: row [1] doesn't have 2 columns", func() {
+		mock.ExpectQuery("SELECT a, b FROM t").WillReturnRows([]string{"a", "b"}, []interface{}{1})
+	})
+	assert.Panics(t, func() {
+		mock.ExpectQuery("SELECT a FROM t").WillReturnRows([]string{"a"}, []interface{}{struct{}{}})
+	})
+}
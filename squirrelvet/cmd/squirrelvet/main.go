@@ -0,0 +1,17 @@
+// Command squirrelvet reports the dynamic strings given to squirrel2 as SQL.
+//
+// Usage:
+//
+//	squirrelvet ./...
+//
+// See the squirrelvet package for the checks it runs.
+package main
+
+import (
+	"github.com/cauanvital/squirrel2/squirrelvet"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(squirrelvet.Analyzer)
+}
@@ -0,0 +1,132 @@
+// Package squirrelvet defines an Analyzer checking that the SQL given to
+// squirrel2 is made of constants, which is what keeps its safeString type
+// free of SQL injection.
+//
+// It reports:
+//
+//   - calls to DangerouslyCastDynamicStringToSafeString;
+//   - conversions of non-constant strings to the SQL string types of
+//     squirrel2, e.g. SafeString(safeString(dynamic));
+//   - calls to Expr whose SQL is concatenated with + from non-constant
+//     operands, e.g. Expr("id = " + column).
+//
+// Sites that were reviewed can be allowed with a //squirrelvet:allow comment,
+// optionally followed by a reason, on their line or on the line before.
+package squirrelvet
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// squirrelPath is the import path of the package whose strings are checked.
+const squirrelPath = "github.com/cauanvital/squirrel2"
+
+// allowDirective marks the reviewed sites that must not be reported.
+const allowDirective = "//squirrelvet:allow"
+
+// Analyzer reports the dynamic strings given to squirrel2 as SQL.
+var Analyzer = &analysis.Analyzer{
+	Name:     "squirrelvet",
+	Doc:      "report dynamic strings given to squirrel2 as SQL\n\nSee the documentation of the squirrelvet package for the checks it runs.",
+	URL:      "https://pkg.go.dev/github.com/cauanvital/squirrel2/squirrelvet",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	allowed := allowedLines(pass)
+	report := func(node ast.Node, format string, args ...interface{}) {
+		position := pass.Fset.Position(node.Pos())
+		if allowed[position.Filename][position.Line] {
+			return
+		}
+		pass.Reportf(node.Pos(), format, args...)
+	}
+
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	inspect.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+
+		if tv, ok := pass.TypesInfo.Types[call.Fun]; ok && tv.IsType() {
+			if len(call.Args) == 1 && isSquirrelString(tv.Type) && !isConstant(pass, call.Args[0]) &&
+				!types.Identical(pass.TypesInfo.TypeOf(call.Args[0]), tv.Type) {
+				report(call, "conversion of a non-constant string to %s", typeName(tv.Type))
+			}
+			return
+		}
+
+		fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+		if !ok || fn.Pkg() == nil || fn.Pkg().Path() != squirrelPath || fn.Type().(*types.Signature).Recv() != nil {
+			return
+		}
+		switch fn.Name() {
+		case "DangerouslyCastDynamicStringToSafeString":
+			report(call, "dangerous cast of a dynamic string to safeString")
+		case "Expr":
+			if len(call.Args) == 0 {
+				return
+			}
+			sql := ast.Unparen(call.Args[0])
+			if bin, ok := sql.(*ast.BinaryExpr); ok && bin.Op == token.ADD && !isConstant(pass, sql) {
+				report(sql, "SQL of Expr is concatenated from non-constant strings; pass the values as args")
+			}
+		}
+	})
+	return nil, nil
+}
+
+// allowedLines returns the lines of each file of pass where diagnostics are
+// allowed by a directive: the line of the directive and the next one.
+func allowedLines(pass *analysis.Pass) map[string]map[int]bool {
+	allowed := make(map[string]map[int]bool)
+	for _, file := range pass.Files {
+		for _, group := range file.Comments {
+			for _, comment := range group.List {
+				if comment.Text != allowDirective && !strings.HasPrefix(comment.Text, allowDirective+" ") {
+					continue
+				}
+				position := pass.Fset.Position(comment.Slash)
+				lines := allowed[position.Filename]
+				if lines == nil {
+					lines = make(map[int]bool)
+					allowed[position.Filename] = lines
+				}
+				lines[position.Line] = true
+				lines[position.Line+1] = true
+			}
+		}
+	}
+	return allowed
+}
+
+// isSquirrelString reports whether t is a string type defined by squirrel2
+// holding SQL, i.e. with a ToSql method, like safeString.
+func isSquirrelString(t types.Type) bool {
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != squirrelPath {
+		return false
+	}
+	basic, ok := named.Underlying().(*types.Basic)
+	if !ok || basic.Info()&types.IsString == 0 {
+		return false
+	}
+	obj, _, _ := types.LookupFieldOrMethod(named, false, named.Obj().Pkg(), "ToSql")
+	_, isMethod := obj.(*types.Func)
+	return isMethod
+}
+
+func isConstant(pass *analysis.Pass, expr ast.Expr) bool {
+	return pass.TypesInfo.Types[expr].Value != nil
+}
+
+func typeName(t types.Type) string {
+	return types.TypeString(t, func(pkg *types.Package) string { return pkg.Name() })
+}
@@ -0,0 +1,12 @@
+package squirrelvet_test
+
+import (
+	"testing"
+
+	"github.com/cauanvital/squirrel2/squirrelvet"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), squirrelvet.Analyzer, "a", "github.com/cauanvital/squirrel2")
+}
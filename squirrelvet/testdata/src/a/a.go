@@ -0,0 +1,31 @@
+package a
+
+import (
+	sq "github.com/cauanvital/squirrel2"
+)
+
+const idColumn = "id"
+
+func f(dynamic string, column sq.Sqlizer) {
+	_ = sq.DangerouslyCastDynamicStringToSafeString(dynamic) // want `dangerous cast of a dynamic string to safeString`
+	_ = sq.DangerouslyCastDynamicStringToSafeString("x")     // want `dangerous cast`
+
+	_ = sq.Expr("id = ?", 1)
+	_ = sq.Expr(idColumn + " = ?")
+	_ = sq.Expr(("a" + "b"))
+
+	safe := sq.SafeString("name")
+	_ = sq.Expr(safe + " = ?")  // want `SQL of Expr is concatenated from non-constant strings`
+	_ = sq.Expr((" x " + safe)) // want `SQL of Expr is concatenated`
+	_ = sq.Expr(safe)
+
+	//squirrelvet:allow reviewed: safe is a column name
+	_ = sq.Expr(safe + " IS NULL")
+}
+
+type nameType string
+
+func g(dynamic string) {
+	// Conversions to other string types aren't reported.
+	_ = nameType(dynamic)
+}
@@ -0,0 +1,42 @@
+// Package squirrel2 is a stub of the squirrel2 API checked by squirrelvet.
+package squirrel2
+
+import "strings"
+
+type safeString string
+
+func (s safeString) ToSql() (string, []interface{}, error) { return string(s), nil, nil }
+
+type ctxKey string
+
+type Sqlizer interface{}
+
+func SafeString(val safeString) safeString { return val }
+
+func DangerouslyCastDynamicStringToSafeString(val string) safeString {
+	return safeString(val) // want `conversion of a non-constant string to squirrel2.safeString`
+}
+
+func Expr(sql safeString, args ...interface{}) Sqlizer { return nil }
+
+type builder struct{}
+
+// Expr methods aren't the Expr function.
+func (builder) Expr(sql safeString) builder { return builder{} }
+
+func internal(dynamic string, column safeString) {
+	_ = SafeString(safeString(dynamic)) // want `conversion of a non-constant string to squirrel2.safeString`
+	_ = safeString("id")
+	_ = safeString(column)
+	_ = safeString(strings.Repeat(",?", 3)) // want `conversion of a non-constant string`
+
+	//squirrelvet:allow made of quoted identifiers
+	_ = safeString(strings.Join([]string{dynamic}, "."))
+	_ = safeString(dynamic) //squirrelvet:allowed is not the directive // want `conversion`
+
+	_ = safeString(dynamic) //squirrelvet:allow
+
+	_ = builder{}.Expr("a = " + column)
+	// Only the string types holding SQL are checked.
+	_ = ctxKey(dynamic)
+}
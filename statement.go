@@ -1,9 +1,13 @@
 package squirrel2
 
+import "time"
+
 // StatementBuilderType is the type of StatementBuilder.
 type statementBuilderType struct {
 	placeholderFormat PlaceholderFormat
 	runWith           BaseRunner
+	timeout           time.Duration
+	tags              sqlTags
 	whereParts        []Sqlizer
 }
 
@@ -58,6 +62,28 @@ func (b statementBuilderType) RunWith(runner BaseRunner) statementBuilderType {
 	return b
 }
 
+// Timeout sets the default Timeout of child builders.
+func (b statementBuilderType) Timeout(d time.Duration) statementBuilderType {
+	b.timeout = d
+	return b
+}
+
+// Tag adds a tag to the comment of the statements of child builders.
+//
+// See SelectBuilder.Tag for more information.
+func (b statementBuilderType) Tag(key, value string) statementBuilderType {
+	b.tags = b.tags.with(key, value)
+	return b
+}
+
+// TagPosition sets where child builders put the comment holding their tags.
+//
+// See SelectBuilder.TagPosition for more information.
+func (b statementBuilderType) TagPosition(p TagPosition) statementBuilderType {
+	b.tags.position = p
+	return b
+}
+
 // Where adds WHERE expressions to the query.
 //
 // See SelectBuilder.Where for more information.
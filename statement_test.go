@@ -19,7 +19,7 @@ func TestStatementBuilderPlaceholderFormat(t *testing.T) {
 	db := &DBStub{}
 	sb := StatementBuilder.RunWith(db).PlaceholderFormat(Dollar)
 
-	sb.Select("test").Where(Expr("x = ?")).Exec()
+	sb.Select("test").Where(Expr("x = ?", 1)).Exec()
 	assert.Equal(t, "SELECT test WHERE x = $1", db.LastExecSql)
 }
 
@@ -1,9 +1,12 @@
 package squirrel2
 
 import (
+	"container/list"
+	"context"
 	"database/sql"
 	"fmt"
 	"sync"
+	"time"
 )
 
 // Prepareer is the interface that wraps the Prepare method.
@@ -23,73 +26,293 @@ type DBProxy interface {
 
 // NOTE: NewStmtCache is defined in stmtcacher_ctx.go (Go >= 1.8) or stmtcacher_noctx.go (Go < 1.8).
 
+// StmtCacheOptions configures the eviction policy of a StmtCache.
+type StmtCacheOptions struct {
+	// MaxSize, if positive, is the number of statements the cache holds. The
+	// least recently used statement is evicted to make room for a new one.
+	MaxSize int
+	// TTL, if positive, is how long a statement is cached after it's
+	// prepared.
+	TTL time.Duration
+}
+
+// StmtCacheStats holds the counters of a StmtCache.
+type StmtCacheStats struct {
+	// Hits counts the lookups that found a cached statement.
+	Hits uint64
+	// Misses counts the lookups that prepared a statement.
+	Misses uint64
+	// Evictions counts the statements removed from the cache because it was
+	// full or they expired.
+	Evictions uint64
+	// Size is the number of statements currently cached.
+	Size int
+}
+
 // StmtCache wraps and delegates down to a Preparer type
 //
 // It also automatically prepares all statements sent to the underlying Preparer calls
 // for Exec, Query and QueryRow and caches the returns *sql.Stmt using the provided
 // query as the key. So that it can be automatically re-used.
+//
+// The cache is unbounded unless it's created with NewStmtCacheWithOptions.
+// Evicted statements are closed once the calls using them return, except the
+// ones returned by Prepare, which are closed by Clear.
 type StmtCache struct {
-	prep  Preparer
-	cache map[string]*sql.Stmt
-	mu    sync.Mutex
+	prep Preparer
+	opts StmtCacheOptions
+
+	mu sync.Mutex
+	// cache maps queries to their element of lru, which holds a
+	// *stmtCacheEntry. The most recently used statement is at the front.
+	cache map[string]*list.Element
+	lru   *list.List
+	// pending holds the statements being prepared, so that concurrent
+	// lookups of a query wait for a single Prepare.
+	pending map[string]*pendingStmt
+	// pinned holds the pinned entries evicted from the cache, for Clear to
+	// close them.
+	pinned []*stmtCacheEntry
+	stats  StmtCacheStats
 }
 
-// Prepare delegates down to the underlying Preparer and caches the result
-// using the provided query as a key
-func (sc *StmtCache) Prepare(query string) (*sql.Stmt, error) {
+type stmtCacheEntry struct {
+	query     string
+	stmt      *sql.Stmt
+	expiresAt time.Time
+	// refs counts the calls using stmt. An evicted stmt is closed once it
+	// drops to zero.
+	refs    int
+	evicted bool
+	// pinned is set once stmt is returned by Prepare. It's then only closed
+	// by Clear, as the caller may keep it.
+	pinned bool
+}
+
+type pendingStmt struct {
+	done  chan struct{}
+	entry *stmtCacheEntry
+	err   error
+}
+
+func newStmtCache(prep Preparer, opts StmtCacheOptions) *StmtCache {
+	return &StmtCache{
+		prep:    prep,
+		opts:    opts,
+		cache:   make(map[string]*list.Element),
+		lru:     list.New(),
+		pending: make(map[string]*pendingStmt),
+	}
+}
+
+// acquire returns the cache entry of query, preparing it with prepare on a
+// miss. The entry must be given back to release once its stmt isn't used
+// anymore. sc.mu isn't held while preparing.
+func (sc *StmtCache) acquire(ctx context.Context, query string, prepare func(query string) (*sql.Stmt, error)) (*stmtCacheEntry, error) {
+	for {
+		sc.mu.Lock()
+		if elem, ok := sc.cache[query]; ok {
+			e := elem.Value.(*stmtCacheEntry)
+			if sc.opts.TTL <= 0 || time.Now().Before(e.expiresAt) {
+				sc.lru.MoveToFront(elem)
+				e.refs++
+				sc.stats.Hits++
+				sc.mu.Unlock()
+				return e, nil
+			}
+			toClose := sc.evictLocked(elem)
+			sc.mu.Unlock()
+			closeStmts(toClose)
+			continue
+		}
+
+		if p, ok := sc.pending[query]; ok {
+			sc.mu.Unlock()
+			select {
+			case <-p.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if p.err != nil {
+				return nil, p.err
+			}
+			// Look the statement up again, as it may have been evicted
+			// already.
+			continue
+		}
+
+		p := &pendingStmt{done: make(chan struct{})}
+		sc.pending[query] = p
+		sc.stats.Misses++
+		sc.mu.Unlock()
+
+		// The statement is prepared for every caller waiting for it, so
+		// the first one giving up mustn't cancel it.
+		go sc.prepare(p, query, prepare)
+		select {
+		case <-p.done:
+		case <-ctx.Done():
+			go func() {
+				<-p.done
+				if p.err == nil {
+					sc.release(p.entry)
+				}
+			}()
+			return nil, ctx.Err()
+		}
+		return p.entry, p.err
+	}
+}
+
+// prepare prepares query for the pending lookup p, and caches the statement
+// with a reference held for the caller that started it.
+func (sc *StmtCache) prepare(p *pendingStmt, query string, prepare func(query string) (*sql.Stmt, error)) {
+	stmt, err := prepare(query)
+
+	sc.mu.Lock()
+	delete(sc.pending, query)
+	var toClose []*sql.Stmt
+	if err == nil {
+		p.entry = &stmtCacheEntry{query: query, stmt: stmt, refs: 1}
+		if sc.opts.TTL > 0 {
+			p.entry.expiresAt = time.Now().Add(sc.opts.TTL)
+		}
+		sc.cache[query] = sc.lru.PushFront(p.entry)
+		for sc.opts.MaxSize > 0 && sc.lru.Len() > sc.opts.MaxSize {
+			toClose = append(toClose, sc.evictLocked(sc.lru.Back())...)
+		}
+	}
+	p.err = err
+	close(p.done)
+	sc.mu.Unlock()
+	closeStmts(toClose)
+}
+
+// release gives back an entry returned by acquire.
+func (sc *StmtCache) release(e *stmtCacheEntry) {
+	sc.mu.Lock()
+	e.refs--
+	closeNow := e.evicted && e.refs == 0 && !e.pinned
+	sc.mu.Unlock()
+	if closeNow {
+		closeStmts([]*sql.Stmt{e.stmt})
+	}
+}
+
+// evictLocked removes elem from the cache, returning its stmt if it must be
+// closed now.
+func (sc *StmtCache) evictLocked(elem *list.Element) []*sql.Stmt {
+	e := sc.lru.Remove(elem).(*stmtCacheEntry)
+	delete(sc.cache, e.query)
+	e.evicted = true
+	sc.stats.Evictions++
+	if e.pinned {
+		sc.pinned = append(sc.pinned, e)
+		return nil
+	}
+	if e.refs > 0 {
+		return nil
+	}
+	return []*sql.Stmt{e.stmt}
+}
+
+// closeStmts closes the stmts evicted from a cache. Their errors are
+// dropped, as there's no caller to report them to.
+func closeStmts(stmts []*sql.Stmt) {
+	for _, stmt := range stmts {
+		if stmt != nil {
+			_ = stmt.Close()
+		}
+	}
+}
+
+// Stats returns the counters of the cache.
+func (sc *StmtCache) Stats() StmtCacheStats {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
+	stats := sc.stats
+	stats.Size = sc.lru.Len()
+	return stats
+}
 
-	stmt, ok := sc.cache[query]
-	if ok {
-		return stmt, nil
+// Prepare delegates down to the underlying Preparer and caches the result
+// using the provided query as a key
+//
+// The returned statement stays open until Clear is called, even if it's
+// evicted from the cache before.
+func (sc *StmtCache) Prepare(query string) (*sql.Stmt, error) {
+	e, err := sc.acquire(context.Background(), query, sc.prep.Prepare)
+	if err != nil {
+		return nil, err
 	}
-	stmt, err := sc.prep.Prepare(query)
-	if err == nil {
-		sc.cache[query] = stmt
+	sc.pin(e)
+	return e.stmt, nil
+}
+
+// pin releases an entry returned by acquire, keeping its stmt open until
+// Clear is called.
+func (sc *StmtCache) pin(e *stmtCacheEntry) {
+	sc.mu.Lock()
+	e.refs--
+	if e.evicted && !e.pinned {
+		sc.pinned = append(sc.pinned, e)
 	}
-	return stmt, err
+	e.pinned = true
+	sc.mu.Unlock()
 }
 
 // Exec delegates down to the underlying Preparer using a prepared statement
 func (sc *StmtCache) Exec(query string, args ...interface{}) (res sql.Result, err error) {
-	stmt, err := sc.Prepare(query)
+	e, err := sc.acquire(context.Background(), query, sc.prep.Prepare)
 	if err != nil {
 		return
 	}
-	return stmt.Exec(args...)
+	defer sc.release(e)
+	return e.stmt.Exec(args...)
 }
 
 // Query delegates down to the underlying Preparer using a prepared statement
 func (sc *StmtCache) Query(query string, args ...interface{}) (rows *sql.Rows, err error) {
-	stmt, err := sc.Prepare(query)
+	e, err := sc.acquire(context.Background(), query, sc.prep.Prepare)
 	if err != nil {
 		return
 	}
-	return stmt.Query(args...)
+	// Rows stay valid if the statement is closed while they're read.
+	defer sc.release(e)
+	return e.stmt.Query(args...)
 }
 
 // QueryRow delegates down to the underlying Preparer using a prepared statement
 func (sc *StmtCache) QueryRow(query string, args ...interface{}) RowScanner {
-	stmt, err := sc.Prepare(query)
+	e, err := sc.acquire(context.Background(), query, sc.prep.Prepare)
 	if err != nil {
 		return &Row{err: err}
 	}
-	return stmt.QueryRow(args...)
+	defer sc.release(e)
+	return e.stmt.QueryRow(args...)
 }
 
 // Clear removes and closes all the currently cached prepared statements
 func (sc *StmtCache) Clear() (err error) {
 	sc.mu.Lock()
-	defer sc.mu.Unlock()
-
-	for key, stmt := range sc.cache {
-		delete(sc.cache, key)
-
-		if stmt == nil {
-			continue
+	var toClose []*sql.Stmt
+	cleared := sc.pinned
+	sc.pinned = nil
+	for elem := sc.lru.Front(); elem != nil; elem = sc.lru.Front() {
+		e := sc.lru.Remove(elem).(*stmtCacheEntry)
+		delete(sc.cache, e.query)
+		e.evicted = true
+		cleared = append(cleared, e)
+	}
+	for _, e := range cleared {
+		e.pinned = false
+		if e.refs == 0 && e.stmt != nil {
+			toClose = append(toClose, e.stmt)
 		}
+	}
+	sc.mu.Unlock()
 
+	for _, stmt := range toClose {
 		if cerr := stmt.Close(); cerr != nil {
 			err = cerr
 		}
@@ -102,20 +325,132 @@ func (sc *StmtCache) Clear() (err error) {
 	return
 }
 
+// DBProxyBeginner groups the DBProxy interface with a Begin method returning
+// a transaction that shares the statement cache.
 type DBProxyBeginner interface {
 	DBProxy
-	Begin() (*sql.Tx, error)
+	Begin() (*StmtCacheTx, error)
 }
 
+// NOTE: NewStmtCacheProxy is defined in stmtcacher_ctx.go (Go >= 1.8) or stmtcacher_noctx.go (Go < 1.8).
+
 type stmtCacheProxy struct {
-	DBProxy
+	*StmtCache
 	db *sql.DB
 }
 
-func NewStmtCacheProxy(db *sql.DB) DBProxyBeginner {
-	return &stmtCacheProxy{DBProxy: NewStmtCache(db), db: db}
+func (sp *stmtCacheProxy) Begin() (*StmtCacheTx, error) {
+	tx, err := sp.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return newStmtCacheTx(sp.StmtCache, tx), nil
+}
+
+// StmtCacheTx is a transaction begun by the proxy returned by
+// NewStmtCacheProxy. It runs statements by binding the statements cached by
+// the proxy to the transaction, so they're prepared once for both.
+//
+// The statements bound to the transaction are released when it's committed
+// or rolled back.
+type StmtCacheTx struct {
+	tx    *sql.Tx
+	cache *StmtCache
+
+	mu sync.Mutex
+	// stmts holds the statements bound to tx, by query.
+	stmts map[string]*sql.Stmt
+}
+
+func newStmtCacheTx(cache *StmtCache, tx *sql.Tx) *StmtCacheTx {
+	return &StmtCacheTx{tx: tx, cache: cache, stmts: make(map[string]*sql.Stmt)}
+}
+
+// stmt returns the statement of query bound to the transaction. On the first
+// use of query, it binds the statement returned by acquire with bind.
+func (t *StmtCacheTx) stmt(query string, acquire func() (*stmtCacheEntry, error), bind func(*sql.Stmt) *sql.Stmt) (*sql.Stmt, error) {
+	t.mu.Lock()
+	stmt, ok := t.stmts[query]
+	t.mu.Unlock()
+	if ok {
+		return stmt, nil
+	}
+
+	e, err := acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer t.cache.release(e)
+	stmt = bind(e.stmt)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stmts == nil {
+		// The transaction is done; let database/sql report it.
+		return stmt, nil
+	}
+	if prev, ok := t.stmts[query]; ok {
+		stmt.Close()
+		return prev, nil
+	}
+	t.stmts[query] = stmt
+	return stmt, nil
+}
+
+func (t *StmtCacheTx) stmtNoContext(query string) (*sql.Stmt, error) {
+	return t.stmt(query,
+		func() (*stmtCacheEntry, error) {
+			return t.cache.acquire(context.Background(), query, t.cache.prep.Prepare)
+		},
+		t.tx.Stmt)
+}
+
+// Exec executes query in the transaction using a cached prepared statement.
+func (t *StmtCacheTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := t.stmtNoContext(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Exec(args...)
+}
+
+// Query executes query in the transaction using a cached prepared statement.
+func (t *StmtCacheTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	stmt, err := t.stmtNoContext(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Query(args...)
+}
+
+// QueryRow executes query in the transaction using a cached prepared
+// statement.
+func (t *StmtCacheTx) QueryRow(query string, args ...interface{}) RowScanner {
+	stmt, err := t.stmtNoContext(query)
+	if err != nil {
+		return &Row{err: err}
+	}
+	return stmt.QueryRow(args...)
+}
+
+// Commit commits the transaction and releases its statements.
+func (t *StmtCacheTx) Commit() error {
+	defer t.releaseStmts()
+	return t.tx.Commit()
 }
 
-func (sp *stmtCacheProxy) Begin() (*sql.Tx, error) {
-	return sp.db.Begin()
+// Rollback rolls the transaction back and releases its statements.
+func (t *StmtCacheTx) Rollback() error {
+	defer t.releaseStmts()
+	return t.tx.Rollback()
+}
+
+func (t *StmtCacheTx) releaseStmts() {
+	t.mu.Lock()
+	stmts := t.stmts
+	t.stmts = nil
+	t.mu.Unlock()
+	for _, stmt := range stmts {
+		stmt.Close()
+	}
 }
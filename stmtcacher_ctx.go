@@ -29,7 +29,17 @@ type DBProxyContext interface {
 //
 // Stmts are cached based on the string value of their queries.
 func NewStmtCache(prep PreparerContext) *StmtCache {
-	return &StmtCache{prep: prep, cache: make(map[string]*sql.Stmt)}
+	return newStmtCache(prep, StmtCacheOptions{})
+}
+
+// NewStmtCacheWithOptions returns a *StmtCache like NewStmtCache, bounded
+// according to opts.
+//
+// Ex:
+//
+//	NewStmtCacheWithOptions(db, StmtCacheOptions{MaxSize: 500, TTL: time.Hour})
+func NewStmtCacheWithOptions(prep PreparerContext, opts StmtCacheOptions) *StmtCache {
+	return newStmtCache(prep, opts)
 }
 
 // NewStmtCacher is deprecated
@@ -41,45 +51,114 @@ func NewStmtCacher(prep PreparerContext) DBProxyContext {
 
 // PrepareContext delegates down to the underlying PreparerContext and caches the result
 // using the provided query as a key
+//
+// The returned statement stays open until Clear is called, even if it's
+// evicted from the cache before.
 func (sc *StmtCache) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	e, err := sc.acquireContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	sc.pin(e)
+	return e.stmt, nil
+}
+
+func (sc *StmtCache) acquireContext(ctx context.Context, query string) (*stmtCacheEntry, error) {
 	ctxPrep, ok := sc.prep.(PreparerContext)
 	if !ok {
 		return nil, ErrNoContextSupport
 	}
-	sc.mu.Lock()
-	defer sc.mu.Unlock()
-	stmt, ok := sc.cache[query]
-	if ok {
-		return stmt, nil
-	}
-	stmt, err := ctxPrep.PrepareContext(ctx, query)
-	if err == nil {
-		sc.cache[query] = stmt
-	}
-	return stmt, err
+	// The values of ctx are kept, e.g. for tracing, but not its deadline:
+	// each caller waits for the statement with its own.
+	prepCtx := context.WithoutCancel(ctx)
+	return sc.acquire(ctx, query, func(query string) (*sql.Stmt, error) {
+		return ctxPrep.PrepareContext(prepCtx, query)
+	})
 }
 
 // ExecContext delegates down to the underlying PreparerContext using a prepared statement
 func (sc *StmtCache) ExecContext(ctx context.Context, query string, args ...interface{}) (res sql.Result, err error) {
-	stmt, err := sc.PrepareContext(ctx, query)
+	e, err := sc.acquireContext(ctx, query)
 	if err != nil {
 		return
 	}
-	return stmt.ExecContext(ctx, args...)
+	defer sc.release(e)
+	return e.stmt.ExecContext(ctx, args...)
 }
 
 // QueryContext delegates down to the underlying PreparerContext using a prepared statement
 func (sc *StmtCache) QueryContext(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, err error) {
-	stmt, err := sc.PrepareContext(ctx, query)
+	e, err := sc.acquireContext(ctx, query)
 	if err != nil {
 		return
 	}
-	return stmt.QueryContext(ctx, args...)
+	defer sc.release(e)
+	return e.stmt.QueryContext(ctx, args...)
 }
 
 // QueryRowContext delegates down to the underlying PreparerContext using a prepared statement
 func (sc *StmtCache) QueryRowContext(ctx context.Context, query string, args ...interface{}) RowScanner {
-	stmt, err := sc.PrepareContext(ctx, query)
+	e, err := sc.acquireContext(ctx, query)
+	if err != nil {
+		return &Row{err: err}
+	}
+	defer sc.release(e)
+	return e.stmt.QueryRowContext(ctx, args...)
+}
+
+// DBProxyBeginnerContext groups the DBProxyBeginner and DBProxyContext
+// interfaces, along with a BeginTx method returning a transaction that shares
+// the statement cache.
+type DBProxyBeginnerContext interface {
+	DBProxyBeginner
+	PreparerContext
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*StmtCacheTx, error)
+}
+
+// NewStmtCacheProxy returns a DBProxy caching the statements it runs on db, as
+// a StmtCache does, that can begin transactions sharing its cache.
+func NewStmtCacheProxy(db *sql.DB) DBProxyBeginnerContext {
+	return &stmtCacheProxy{StmtCache: NewStmtCache(db), db: db}
+}
+
+func (sp *stmtCacheProxy) BeginTx(ctx context.Context, opts *sql.TxOptions) (*StmtCacheTx, error) {
+	tx, err := sp.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return newStmtCacheTx(sp.StmtCache, tx), nil
+}
+
+func (t *StmtCacheTx) stmtContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return t.stmt(query,
+		func() (*stmtCacheEntry, error) { return t.cache.acquireContext(ctx, query) },
+		func(stmt *sql.Stmt) *sql.Stmt { return t.tx.StmtContext(ctx, stmt) })
+}
+
+// ExecContext executes query in the transaction using a cached prepared
+// statement.
+func (t *StmtCacheTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := t.stmtContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.ExecContext(ctx, args...)
+}
+
+// QueryContext executes query in the transaction using a cached prepared
+// statement.
+func (t *StmtCacheTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	stmt, err := t.stmtContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.QueryContext(ctx, args...)
+}
+
+// QueryRowContext executes query in the transaction using a cached prepared
+// statement.
+func (t *StmtCacheTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) RowScanner {
+	stmt, err := t.stmtContext(ctx, query)
 	if err != nil {
 		return &Row{err: err}
 	}
@@ -4,7 +4,10 @@
 package squirrel2
 
 import (
+	"context"
+	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -20,3 +23,122 @@ func TestStmtCacherPrepareContext(t *testing.T) {
 	sc.PrepareContext(ctx, query)
 	assert.Equal(t, 1, db.PrepareCount, "expected 1 Prepare, got %d", db.PrepareCount)
 }
+
+func TestStmtCacheProxyTx(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{columns: []string{"x"}})
+	proxy := NewStmtCacheProxy(db)
+
+	_, err := Update("t").Set("x", 1).RunWith(proxy).Exec()
+	assert.NoError(t, err)
+
+	tx, err := proxy.BeginTx(ctx, nil)
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = Update("t").Set("x", 1).RunWith(tx).ExecContext(ctx)
+		assert.NoError(t, err)
+	}
+	rows, err := Select("x").From("t").RunWith(tx).QueryContext(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, rows.Close())
+	assert.Len(t, tx.stmts, 2)
+	assert.NoError(t, tx.Commit())
+	assert.Nil(t, tx.stmts)
+
+	// The UPDATE was prepared on the connection of the transaction, while
+	// the SELECT is prepared on another one for the cache, then on the
+	// connection of the transaction by database/sql.
+	assert.Equal(t, []string{
+		"PREPARE UPDATE t SET x = ?", "UPDATE t SET x = ?",
+		"BEGIN", "UPDATE t SET x = ?", "UPDATE t SET x = ?",
+		"PREPARE SELECT x FROM t", "PREPARE SELECT x FROM t", "SELECT x FROM t",
+		"COMMIT",
+	}, fake.statements())
+
+	stats := proxy.(*stmtCacheProxy).Stats()
+	assert.Equal(t, StmtCacheStats{Hits: 1, Misses: 2, Size: 2}, stats)
+
+	_, err = tx.ExecContext(ctx, "UPDATE t SET x = ?", 1)
+	assert.Equal(t, sql.ErrTxDone, err)
+}
+
+func TestStmtCacheProxyBegin(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	proxy := NewStmtCacheProxy(db)
+
+	tx, err := proxy.Begin()
+	assert.NoError(t, err)
+	_, err = Delete("t").RunWith(tx).Exec()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Rollback())
+
+	assert.Equal(t, []string{
+		"BEGIN", "PREPARE DELETE FROM t", "PREPARE DELETE FROM t", "DELETE FROM t", "ROLLBACK",
+	}, fake.statements())
+}
+
+// slowPreparer blocks each PrepareContext until unblock is closed, then
+// fails if its context is done.
+type slowPreparer struct {
+	*sql.DB
+	started, unblock chan struct{}
+}
+
+func (p slowPreparer) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	close(p.started)
+	<-p.unblock
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return p.DB.PrepareContext(ctx, query)
+}
+
+func TestStmtCachePrepareDetached(t *testing.T) {
+	db, _ := newFakeDB(fakeResult{})
+	prep := slowPreparer{DB: db, started: make(chan struct{}), unblock: make(chan struct{})}
+	sc := NewStmtCache(prep)
+
+	// The caller starting the Prepare gives up, while another one waits.
+	first, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() {
+		_, err := sc.ExecContext(first, "SELECT 1")
+		errs <- err
+	}()
+	<-prep.started
+	go func() {
+		_, err := sc.ExecContext(context.Background(), "SELECT 1")
+		errs <- err
+	}()
+	cancel()
+	assert.Equal(t, context.Canceled, <-errs)
+
+	close(prep.unblock)
+	assert.NoError(t, <-errs)
+	assert.Equal(t, StmtCacheStats{Hits: 1, Misses: 1, Size: 1}, sc.Stats())
+
+	// The reference held for the first caller is released.
+	assert.Eventually(t, func() bool {
+		sc.mu.Lock()
+		defer sc.mu.Unlock()
+		return sc.lru.Front().Value.(*stmtCacheEntry).refs == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestStmtCachePrepareContextPinned(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	sc := NewStmtCacheWithOptions(db, StmtCacheOptions{MaxSize: 1})
+
+	stmt, err := sc.PrepareContext(ctx, "SELECT 1")
+	assert.NoError(t, err)
+	_, err = sc.ExecContext(ctx, "SELECT 2")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), sc.Stats().Evictions)
+
+	// The evicted statement stays open until Clear.
+	assert.Empty(t, closedStmts(fake))
+	_, err = stmt.ExecContext(ctx)
+	assert.NoError(t, err)
+
+	assert.NoError(t, sc.Clear())
+	assert.ElementsMatch(t, []string{"SELECT 1", "SELECT 2"}, closedStmts(fake))
+}
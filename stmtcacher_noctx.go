@@ -3,15 +3,19 @@
 
 package squirrel2
 
-import (
-	"database/sql"
-)
+import "database/sql"
 
 // NewStmtCacher returns a DBProxy wrapping prep that caches Prepared Stmts.
 //
 // Stmts are cached based on the string value of their queries.
 func NewStmtCache(prep Preparer) *StmtCache {
-	return &StmtCacher{prep: prep, cache: make(map[string]*sql.Stmt)}
+	return newStmtCache(prep, StmtCacheOptions{})
+}
+
+// NewStmtCacheWithOptions returns a *StmtCache like NewStmtCache, bounded
+// according to opts.
+func NewStmtCacheWithOptions(prep Preparer, opts StmtCacheOptions) *StmtCache {
+	return newStmtCache(prep, opts)
 }
 
 // NewStmtCacher is deprecated
@@ -20,3 +24,9 @@ func NewStmtCache(prep Preparer) *StmtCache {
 func NewStmtCacher(prep Preparer) DBProxy {
 	return NewStmtCache(prep)
 }
+
+// NewStmtCacheProxy returns a DBProxy caching the statements it runs on db, as
+// a StmtCache does, that can begin transactions sharing its cache.
+func NewStmtCacheProxy(db *sql.DB) DBProxyBeginner {
+	return &stmtCacheProxy{StmtCache: NewStmtCache(db), db: db}
+}
@@ -1,7 +1,11 @@
 package squirrel2
 
 import (
+	"context"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -24,3 +28,118 @@ func TestStmtCachePrepare(t *testing.T) {
 	sc.Prepare(query)
 	assert.Equal(t, 2, db.PrepareCount, "expected 2 Prepare, got %d", db.PrepareCount)
 }
+
+// closedStmts returns the queries of the statements closed by fake.
+func closedStmts(fake *fakeDB) []string {
+	var closed []string
+	for _, s := range fake.statements() {
+		if strings.HasPrefix(s, "CLOSE ") {
+			closed = append(closed, strings.TrimPrefix(s, "CLOSE "))
+		}
+	}
+	return closed
+}
+
+func TestStmtCacheLRU(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	sc := NewStmtCacheWithOptions(db, StmtCacheOptions{MaxSize: 2})
+
+	for _, query := range []string{"SELECT 1", "SELECT 2", "SELECT 1", "SELECT 3", "SELECT 1", "SELECT 2"} {
+		_, err := sc.Exec(query)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"SELECT 2", "SELECT 3"}, closedStmts(fake))
+	assert.Equal(t, StmtCacheStats{Hits: 2, Misses: 4, Evictions: 2, Size: 2}, sc.Stats())
+
+	assert.NoError(t, sc.Clear())
+	assert.Equal(t, []string{"SELECT 2", "SELECT 3", "SELECT 2", "SELECT 1"}, closedStmts(fake))
+	assert.Equal(t, 0, sc.Stats().Size)
+}
+
+func TestStmtCacheTTL(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	sc := NewStmtCacheWithOptions(db, StmtCacheOptions{TTL: time.Hour})
+
+	_, err := sc.Exec("SELECT 1")
+	assert.NoError(t, err)
+	_, err = sc.Exec("SELECT 1")
+	assert.NoError(t, err)
+	assert.Equal(t, StmtCacheStats{Hits: 1, Misses: 1, Size: 1}, sc.Stats())
+
+	sc.mu.Lock()
+	sc.lru.Front().Value.(*stmtCacheEntry).expiresAt = time.Now().Add(-time.Second)
+	sc.mu.Unlock()
+
+	_, err = sc.Exec("SELECT 1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"SELECT 1"}, closedStmts(fake))
+	assert.Equal(t, StmtCacheStats{Hits: 1, Misses: 2, Evictions: 1, Size: 1}, sc.Stats())
+}
+
+func TestStmtCacheEvictInUse(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{columns: []string{"x"}})
+	sc := NewStmtCacheWithOptions(db, StmtCacheOptions{MaxSize: 1})
+
+	// Evict SELECT 1 while Exec is running it.
+	fake.execErr = func(query string) error {
+		if query == "SELECT 1" {
+			_, err := sc.Exec("SELECT 2")
+			assert.NoError(t, err)
+			assert.Empty(t, closedStmts(fake))
+		}
+		return nil
+	}
+	_, err := sc.Exec("SELECT 1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"SELECT 1"}, closedStmts(fake))
+	assert.Equal(t, uint64(1), sc.Stats().Evictions)
+}
+
+func TestStmtCachePrepareUnlocked(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	sc := NewStmtCache(db)
+	_, err := sc.Exec("SELECT fast")
+	assert.NoError(t, err)
+
+	started, unblock := make(chan struct{}), make(chan struct{})
+	fake.prepareHook = func(query string) {
+		if query == "SELECT slow" {
+			close(started)
+			<-unblock
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := sc.ExecContext(context.Background(), "SELECT slow")
+			assert.NoError(t, err)
+		}()
+	}
+	<-started
+
+	// Cached statements don't wait for the slow Prepare.
+	_, err = sc.Exec("SELECT fast")
+	assert.NoError(t, err)
+
+	// A waiting lookup gives up with its context.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = sc.PrepareContext(ctx, "SELECT slow")
+	assert.Equal(t, context.Canceled, err)
+
+	close(unblock)
+	wg.Wait()
+
+	prepares := 0
+	for _, s := range fake.statements() {
+		if s == "PREPARE SELECT slow" {
+			prepares++
+		}
+	}
+	assert.Equal(t, 1, prepares)
+	assert.Equal(t, StmtCacheStats{Hits: 3, Misses: 2, Size: 2}, sc.Stats())
+}
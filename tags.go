@@ -0,0 +1,105 @@
+package squirrel2
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// TagPosition is where the comment holding the tags of a statement goes.
+type TagPosition int
+
+const (
+	// TagsAppended puts the comment at the end of the statement, as the
+	// sqlcommenter specification recommends.
+	TagsAppended TagPosition = iota
+	// TagsPrepended puts the comment at the start of the statement, where
+	// it survives the truncation of long statements in logs.
+	TagsPrepended
+)
+
+// sqlTags are the tags of a statement, written to a comment in the
+// sqlcommenter format, e.g. /*route='%2Fusers',service='api'*/.
+type sqlTags struct {
+	// values is shared between copies, so it's copied before it's changed.
+	values   map[string]string
+	position TagPosition
+}
+
+// with returns a copy of t with key set to value.
+func (t sqlTags) with(key, value string) sqlTags {
+	values := make(map[string]string, len(t.values)+1)
+	for k, v := range t.values {
+		values[k] = v
+	}
+	values[key] = value
+	t.values = values
+	return t
+}
+
+// withDefaults returns a copy of t that also has the tags of defaults that it
+// doesn't set.
+func (t sqlTags) withDefaults(defaults map[string]string) sqlTags {
+	for k, v := range defaults {
+		if _, ok := t.values[k]; !ok {
+			t = t.with(k, v)
+		}
+	}
+	return t
+}
+
+// apply returns sql with the comment of t.
+func (t sqlTags) apply(sql string) string {
+	if len(t.values) == 0 {
+		return sql
+	}
+	keys := make([]string, 0, len(t.values))
+	for k := range t.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = escapeTag(k) + "='" + escapeTag(t.values[k]) + "'"
+	}
+	comment := "/*" + strings.Join(pairs, ",") + "*/"
+	if t.position == TagsPrepended {
+		return comment + " " + sql
+	}
+	return sql + " " + comment
+}
+
+// escapeTag URL-encodes a key or value of a tag. As it encodes "'", "*", "/"
+// and "?", tags can't end their quotes or comment early, or be mistaken for
+// placeholders.
+func escapeTag(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+type tagsKey struct{}
+
+// WithTags returns a copy of ctx holding tags, in addition to the ones of ctx
+// that they don't override. The Context methods of the builders (e.g.
+// QueryContext) add the tags of their context to their statement, unless the
+// builder sets the same tag.
+//
+// Ex:
+//
+//	ctx = WithTags(ctx, map[string]string{"route": r.URL.Path})
+func WithTags(ctx context.Context, tags map[string]string) context.Context {
+	merged := make(map[string]string, len(tags))
+	for k, v := range ContextTags(ctx) {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, tagsKey{}, merged)
+}
+
+// ContextTags returns the tags held by ctx. The map must not be changed.
+func ContextTags(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(tagsKey{}).(map[string]string)
+	return tags
+}
@@ -0,0 +1,91 @@
+package squirrel2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTags(t *testing.T) {
+	b := Select("*").From("users").Where(Eq{"id": 1}).PlaceholderFormat(Dollar).
+		Tag("service", "api").Tag("route", "/users/{id}").Tag("note", "it's ?*/ done")
+
+	sql, args, err := b.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"SELECT * FROM users WHERE id = $1 "+
+			"/*note='it%27s%20%3F%2A%2F%20done',route='%2Fusers%2F%7Bid%7D',service='api'*/",
+		sql)
+	assert.Equal(t, []interface{}{1}, args)
+
+	sql, _, err = b.TagPosition(TagsPrepended).ToSql()
+	assert.NoError(t, err)
+	assert.Regexp(t, `^/\*note=.*\*/ SELECT \* FROM users WHERE id = \$1$`, sql)
+
+	// Tags don't leak between builders sharing a parent.
+	base := Select("*").From("users").Tag("a", "1")
+	_ = base.Tag("b", "2")
+	sql, _, _ = base.ToSql()
+	assert.Equal(t, "SELECT * FROM users /*a='1'*/", sql)
+}
+
+func TestTagsOfSubqueries(t *testing.T) {
+	sub := Select("id").From("admins").Tag("sub", "1")
+	sql, _, err := Insert("users").Select(sub).Tag("a", "1").ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO users SELECT id FROM admins /*sub='1'*/ /*a='1'*/", sql)
+
+	sql, args, err := Select("*").From("users").
+		Where(Expr("id IN (?)", Select("user_id").From("admins").Where(Eq{"level": 1}).Tag("sub", "1"))).
+		Where(Eq{"active": true}).PlaceholderFormat(Dollar).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM users WHERE id IN (SELECT user_id FROM admins WHERE level = $1 /*sub='1'*/) AND active = $2", sql)
+	assert.Equal(t, []interface{}{1, true}, args)
+
+	sql, _, err = NewBatch(Delete("a").Tag("n", "1"), Delete("b").Tag("n", "2")).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "DELETE FROM a /*n='1'*/\n;\nDELETE FROM b /*n='2'*/", sql)
+}
+
+func TestStatementBuilderTags(t *testing.T) {
+	sb := StatementBuilder.Tag("service", "api").TagPosition(TagsPrepended)
+
+	sql, _, err := sb.Update("t").Set("x", 1).Tag("route", "r").ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "/*route='r',service='api'*/ UPDATE t SET x = ?", sql)
+
+	sql, _, err = sb.Delete("t").Tag("service", "worker").ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "/*service='worker'*/ DELETE FROM t", sql)
+
+	sql, _, err = sb.Insert("t").Values(1).TagPosition(TagsAppended).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO t VALUES (?) /*service='api'*/", sql)
+}
+
+func TestContextTags(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{columns: []string{"x"}})
+	ctx := WithTags(context.Background(), map[string]string{"traceparent": "00-abc-01", "route": "ctx"})
+	ctx = WithTags(ctx, map[string]string{"route": "/users"})
+	assert.Equal(t, map[string]string{"traceparent": "00-abc-01", "route": "/users"}, ContextTags(ctx))
+
+	_, err := Update("t").Set("x", 1).Tag("service", "api").RunWith(db).ExecContext(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE t SET x = ? /*route='%2Fusers',service='api',traceparent='00-abc-01'*/", fake.lastSql)
+
+	// The builder's tags win.
+	var x int
+	_ = Select("x").From("t").Tag("route", "builder").RunWith(db).ScanContext(ctx, &x)
+	assert.Equal(t, "SELECT x FROM t /*route='builder',traceparent='00-abc-01'*/", fake.lastSql)
+
+	rows, err := Delete("t").RunWith(db).QueryContext(ctx)
+	assert.NoError(t, err)
+	rows.Close()
+	assert.Equal(t, "DELETE FROM t /*route='%2Fusers',traceparent='00-abc-01'*/", fake.lastSql)
+
+	// Non-Context methods have no context to read tags from.
+	_, err = Insert("t").Values(1).RunWith(db).Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO t VALUES (?)", fake.lastSql)
+}
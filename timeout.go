@@ -0,0 +1,121 @@
+package squirrel2
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// TimeoutError is returned by the statements of a builder whose Timeout
+// expired before they completed. Statements failing because the deadline of
+// the caller's context passed return the error of the driver as is, usually
+// context.DeadlineExceeded.
+type TimeoutError struct {
+	// Timeout is the timeout set on the builder.
+	Timeout time.Duration
+	// Err is the error the statement failed with.
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("statement timed out after %s", e.Timeout)
+	}
+	return fmt.Sprintf("statement timed out after %s: %v", e.Timeout, e.Err)
+}
+
+// Unwrap returns Err, so that errors.Is(err, context.DeadlineExceeded) holds
+// for the TimeoutErrors of database/sql.
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// withTimeout returns a copy of ctx that is canceled after timeout, and the
+// TimeoutError it's canceled with.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc, *TimeoutError) {
+	cause := &TimeoutError{Timeout: timeout}
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, cause)
+	return ctx, cancel, cause
+}
+
+// timeoutErr returns the error of a statement run with ctx, as a TimeoutError
+// if ctx was canceled by its timeout rather than by its parent.
+func timeoutErr(ctx context.Context, cause *TimeoutError, err error) error {
+	if err == nil || context.Cause(ctx) != error(cause) {
+		return err
+	}
+	return &TimeoutError{Timeout: cause.Timeout, Err: err}
+}
+
+func execContextTimeout(ctx context.Context, timeout time.Duration, db ExecerContext, s Sqlizer) (sql.Result, error) {
+	ctx, cancel, cause := withTimeout(ctx, timeout)
+	defer cancel()
+	res, err := ExecContextWith(ctx, db, s)
+	return res, timeoutErr(ctx, cause, err)
+}
+
+func queryContextTimeout(parent context.Context, timeout time.Duration, db QueryerContext, s Sqlizer) (*sql.Rows, error) {
+	ctx, cancel, cause := withTimeout(parent, timeout)
+	rows, err := QueryContextWith(ctx, db, s)
+	if err != nil {
+		cancel()
+		return nil, timeoutErr(ctx, cause, err)
+	}
+	// The timeout also bounds the reading of rows. *sql.Rows can't tell when
+	// they're closed, so ctx is left to expire unless the caller reading them
+	// asked to end it, as documented on Timeout.
+	if t, ok := parent.Value(rowsTimeoutKey{}).(*rowsTimeout); ok {
+		t.ctx, t.cancel, t.cause = ctx, cancel, cause
+	}
+	return rows, nil
+}
+
+func queryRowContextTimeout(ctx context.Context, timeout time.Duration, db QueryRowerContext, s Sqlizer) RowScanner {
+	ctx, cancel, cause := withTimeout(ctx, timeout)
+	row := QueryRowContextWith(ctx, db, s)
+	return &timeoutRow{RowScanner: row, ctx: ctx, cancel: cancel, cause: cause}
+}
+
+// timeoutRow is the RowScanner of a QueryRow run with a timeout, which lasts
+// until it's scanned.
+type timeoutRow struct {
+	RowScanner
+	ctx    context.Context
+	cancel context.CancelFunc
+	cause  *TimeoutError
+}
+
+func (r *timeoutRow) Scan(dest ...interface{}) error {
+	defer r.cancel()
+	return timeoutErr(r.ctx, r.cause, r.RowScanner.Scan(dest...))
+}
+
+type rowsTimeoutKey struct{}
+
+// rowsTimeout receives the timeout of a Query run with a context returned by
+// withRowsTimeout, so that the caller reading its rows can end it.
+type rowsTimeout struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	cause  *TimeoutError
+}
+
+// withRowsTimeout returns a copy of ctx that makes a builder with a Timeout
+// hand the timeout of its Query over to the returned rowsTimeout.
+func withRowsTimeout(ctx context.Context) (context.Context, *rowsTimeout) {
+	t := &rowsTimeout{}
+	return context.WithValue(ctx, rowsTimeoutKey{}, t), t
+}
+
+// end ends the timeout of the Query, if it had one, once its rows are read,
+// and returns err, the error reading them, as a TimeoutError if the timeout
+// caused it.
+func (t *rowsTimeout) end(err error) error {
+	if t.cancel == nil {
+		return err
+	}
+	err = timeoutErr(t.ctx, t.cause, err)
+	t.cancel()
+	return err
+}
@@ -0,0 +1,176 @@
+package squirrel2
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingRunner runs statements until their context is done.
+type blockingRunner struct{}
+
+func (blockingRunner) Exec(string, ...interface{}) (sql.Result, error) {
+	select {}
+}
+
+func (blockingRunner) Query(string, ...interface{}) (*sql.Rows, error) {
+	select {}
+}
+
+func (blockingRunner) QueryRow(string, ...interface{}) RowScanner {
+	select {}
+}
+
+func (blockingRunner) ExecContext(ctx context.Context, _ string, _ ...interface{}) (sql.Result, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingRunner) QueryContext(ctx context.Context, _ string, _ ...interface{}) (*sql.Rows, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingRunner) QueryRowContext(ctx context.Context, _ string, _ ...interface{}) RowScanner {
+	<-ctx.Done()
+	return &Row{err: ctx.Err()}
+}
+
+func assertTimeoutError(t *testing.T, timeout time.Duration, err error) {
+	t.Helper()
+	var timeoutErr *TimeoutError
+	if assert.ErrorAs(t, err, &timeoutErr) {
+		assert.Equal(t, timeout, timeoutErr.Timeout)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, "statement timed out after 10ms: context deadline exceeded", err.Error())
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	timeout := 10 * time.Millisecond
+	ctx := context.Background()
+
+	_, err := Update("t").Set("x", 1).RunWith(blockingRunner{}).Timeout(timeout).ExecContext(ctx)
+	assertTimeoutError(t, timeout, err)
+	_, err = Insert("t").Values(1).RunWith(blockingRunner{}).Timeout(timeout).Exec()
+	assertTimeoutError(t, timeout, err)
+	_, err = Delete("t").RunWith(blockingRunner{}).Timeout(timeout).Query()
+	assertTimeoutError(t, timeout, err)
+	var x int
+	err = Select("x").From("t").RunWith(blockingRunner{}).Timeout(timeout).Scan(&x)
+	assertTimeoutError(t, timeout, err)
+}
+
+func TestTimeoutCallerDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := Delete("t").RunWith(blockingRunner{}).Timeout(time.Hour).ExecContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	var timeoutErr *TimeoutError
+	assert.False(t, errors.As(err, &timeoutErr))
+}
+
+func TestStatementBuilderTimeout(t *testing.T) {
+	sb := StatementBuilder.RunWith(blockingRunner{}).Timeout(10 * time.Millisecond)
+
+	var x int
+	err := sb.Select("x").From("t").ScanContext(context.Background(), &x)
+	assertTimeoutError(t, 10*time.Millisecond, err)
+
+	assert.Equal(t, 10*time.Millisecond, sb.Insert("t").data.Timeout)
+	assert.Equal(t, 10*time.Millisecond, sb.Delete("t").data.Timeout)
+	// Builders may override the default.
+	assert.Equal(t, time.Duration(0), sb.Update("t").Timeout(0).data.Timeout)
+}
+
+func TestTimeoutNotExpired(t *testing.T) {
+	db, _ := newFakeDB(fakeResult{columns: []string{"x"}, rows: [][]driver.Value{{int64(1)}, {int64(2)}}})
+	b := Select("x").From("t").RunWith(db).Timeout(time.Minute)
+
+	// The rows of a Query can be read after it returns.
+	rows, err := b.Query()
+	if assert.NoError(t, err) {
+		var xs []int
+		for rows.Next() {
+			var x int
+			assert.NoError(t, rows.Scan(&x))
+			xs = append(xs, x)
+		}
+		assert.NoError(t, rows.Err())
+		assert.NoError(t, rows.Close())
+		assert.Equal(t, []int{1, 2}, xs)
+	}
+
+	var x int
+	assert.NoError(t, b.ScanContext(context.Background(), &x))
+	assert.Equal(t, 1, x)
+
+	_, err = Update("t").Set("x", 1).RunWith(db).Timeout(time.Minute).Exec()
+	assert.NoError(t, err)
+}
+
+func TestTimeoutNoContextSupport(t *testing.T) {
+	// Hide the Context methods of DBStub.
+	runner := struct{ Runner }{&DBStub{}}
+	_, err := Update("t").Set("x", 1).RunWith(runner).Timeout(time.Second).Exec()
+	assert.Equal(t, ErrNoContextSupport, err)
+}
+
+// ctxRecorder records the context of the last Query run with it.
+type ctxRecorder struct {
+	*sql.DB
+	ctx context.Context
+}
+
+func (r *ctxRecorder) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	r.ctx = ctx
+	return r.DB.QueryContext(ctx, query, args...)
+}
+
+// slowScanner scans an int64 after sleeping, to outlast a timeout.
+type slowScanner struct{ x int64 }
+
+func (s *slowScanner) Scan(src interface{}) error {
+	time.Sleep(30 * time.Millisecond)
+	s.x, _ = src.(int64)
+	return nil
+}
+
+func TestTimeoutRowsEnded(t *testing.T) {
+	db, _ := newFakeDB(fakeResult{columns: []string{"x"}, rows: [][]driver.Value{{int64(1)}, {int64(2)}}})
+	runner := &ctxRecorder{DB: db}
+	b := Select("x").From("t").RunWith(runner).Timeout(time.Minute)
+
+	xs, err := ScanAll[int64](context.Background(), b)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, xs)
+	assert.Equal(t, context.Canceled, runner.ctx.Err())
+
+	for x, err := range Rows[int64](context.Background(), b) {
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), x)
+		break
+	}
+	assert.Equal(t, context.Canceled, runner.ctx.Err())
+}
+
+func TestTimeoutRowsRead(t *testing.T) {
+	db, _ := newFakeDB(fakeResult{columns: []string{"x"}, rows: [][]driver.Value{{int64(1)}, {int64(2)}}})
+	b := Select("x").From("t").RunWith(db).Timeout(10 * time.Millisecond)
+
+	_, err := ScanAll[slowScanner](context.Background(), b)
+	assertTimeoutError(t, 10*time.Millisecond, err)
+
+	for _, err = range Rows[slowScanner](context.Background(), b) {
+		if err != nil {
+			break
+		}
+	}
+	assertTimeoutError(t, 10*time.Millisecond, err)
+}
@@ -0,0 +1,339 @@
+package squirrel2
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// TxBeginner is the interface that wraps the BeginTx method.
+//
+// BeginTx starts a transaction as implemented by database/sql.DB.BeginTx.
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// DefaultTxMaxAttempts is the number of times WithTx runs a transaction that
+// keeps failing with retryable errors, unless TxOptions.MaxAttempts is set.
+const DefaultTxMaxAttempts = 3
+
+// TxOptions configures the transactions run by WithTx. The zero value runs
+// them with the default isolation level of the database, retrying them on
+// serialization failures and deadlocks as classified by IsRetryableTxError.
+type TxOptions struct {
+	// Isolation is the isolation level of the transaction.
+	Isolation sql.IsolationLevel
+	// ReadOnly starts a read-only transaction.
+	ReadOnly bool
+
+	// MaxAttempts is the number of times the transaction is run before
+	// giving up on retryable errors. Zero means DefaultTxMaxAttempts, and one
+	// disables retries.
+	MaxAttempts int
+	// Backoff returns how long to wait before the given retry, counting from
+	// 1. Nil means ExponentialBackoff(10*time.Millisecond, time.Second).
+	Backoff func(retry int) time.Duration
+	// IsRetryable reports whether the transaction should be run again after
+	// failing with err. Nil means IsRetryableTxError.
+	IsRetryable func(err error) bool
+}
+
+// WithTx runs fn in a transaction begun on db, committing it if fn returns nil
+// and rolling it back if fn returns an error or panics. The tx passed to fn is
+// a *Tx, whose WithTx method runs nested transactions in savepoints.
+//
+// If fn or the commit fails with a retryable error, e.g. a serialization
+// failure, the whole transaction is run again after a backoff, so fn must not
+// have side effects outside of the transaction. The last error is returned
+// once opts.MaxAttempts runs have failed.
+//
+// Ex:
+//
+//	err := WithTx(ctx, db, nil, func(tx RunnerContext) error {
+//		_, err := Update("accounts").Set("balance", Expr("balance - ?", amount)).
+//			Where(Eq{"id": from}).RunWith(tx).ExecContext(ctx)
+//		return err
+//	})
+func WithTx(ctx context.Context, db TxBeginner, opts *TxOptions, fn func(tx RunnerContext) error) error {
+	if opts == nil {
+		opts = &TxOptions{}
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultTxMaxAttempts
+	}
+	backoff := opts.Backoff
+	if backoff == nil {
+		backoff = ExponentialBackoff(10*time.Millisecond, time.Second)
+	}
+	isRetryable := opts.IsRetryable
+	if isRetryable == nil {
+		isRetryable = IsRetryableTxError
+	}
+	txOpts := &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly}
+
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, db, txOpts, fn)
+		if err == nil || attempt >= maxAttempts || !isRetryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// runTx runs a single attempt of a WithTx transaction.
+func runTx(ctx context.Context, db TxBeginner, opts *sql.TxOptions, fn func(tx RunnerContext) error) (err error) {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(NewTx(tx)); err != nil {
+		// fn may have rolled tx back itself, e.g. with RollbackOnUnexpected.
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// Tx wraps a database/sql.Tx to run nested transactions in savepoints. It
+// implements RunnerContext, so builders can run with it unchanged.
+//
+// A Tx returned by Begin stands for a savepoint of its parent transaction:
+// committing it releases the savepoint, and rolling it back only undoes the
+// statements run since it was created.
+type Tx struct {
+	tx *sql.Tx
+	// savepoint is the name of the savepoint of a nested Tx, or empty for the
+	// outermost one.
+	savepoint string
+	// savepoints counts the savepoints created in the transaction, to name
+	// them uniquely.
+	savepoints *int
+	done       bool
+}
+
+// NewTx wraps tx in a Tx.
+func NewTx(tx *sql.Tx) *Tx {
+	return &Tx{tx: tx, savepoints: new(int)}
+}
+
+// Begin creates a savepoint named sp_n, where n counts the savepoints of the
+// transaction, and returns the nested Tx standing for it.
+func (t *Tx) Begin(ctx context.Context) (*Tx, error) {
+	if t.done {
+		return nil, sql.ErrTxDone
+	}
+	*t.savepoints++
+	name := "sp_" + strconv.Itoa(*t.savepoints)
+	if _, err := t.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return nil, err
+	}
+	return &Tx{tx: t.tx, savepoint: name, savepoints: t.savepoints}, nil
+}
+
+// Commit commits the transaction, or releases the savepoint of a nested Tx.
+func (t *Tx) Commit() error {
+	if t.savepoint == "" {
+		return t.tx.Commit()
+	}
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	_, err := t.tx.Exec("RELEASE SAVEPOINT " + t.savepoint)
+	return err
+}
+
+// Rollback rolls the transaction back, or rolls a nested Tx back to its
+// savepoint.
+func (t *Tx) Rollback() error {
+	if t.savepoint == "" {
+		return t.tx.Rollback()
+	}
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	if _, err := t.tx.Exec("ROLLBACK TO SAVEPOINT " + t.savepoint); err != nil {
+		return err
+	}
+	_, err := t.tx.Exec("RELEASE SAVEPOINT " + t.savepoint)
+	return err
+}
+
+// WithTx runs fn in a savepoint of t, releasing it if fn returns nil and
+// rolling back to it if fn returns an error or panics. Unlike the WithTx
+// function, it doesn't retry: serialization failures abort the whole
+// transaction, so they are left to the outermost WithTx.
+//
+// Functions that each want a transaction can be composed this way:
+//
+//	func createOrder(ctx context.Context, tx *Tx, o Order) error {
+//		return tx.WithTx(ctx, func(tx RunnerContext) error {
+//			...
+//		})
+//	}
+func (t *Tx) WithTx(ctx context.Context, fn func(tx RunnerContext) error) (err error) {
+	sp, err := t.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = sp.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(sp); err != nil {
+		// fn may have rolled sp back itself, e.g. with RollbackOnUnexpected.
+		if rbErr := sp.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	return sp.Commit()
+}
+
+// Exec executes query in the transaction.
+func (t *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.Exec(query, args...)
+}
+
+// Query executes query in the transaction.
+func (t *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.Query(query, args...)
+}
+
+// QueryRow executes query in the transaction.
+func (t *Tx) QueryRow(query string, args ...interface{}) RowScanner {
+	return t.tx.QueryRow(query, args...)
+}
+
+// ExecContext executes query in the transaction.
+func (t *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, query, args...)
+}
+
+// QueryContext executes query in the transaction.
+func (t *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext executes query in the transaction.
+func (t *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) RowScanner {
+	return t.tx.QueryRowContext(ctx, query, args...)
+}
+
+// WithTx runs fn in a transaction begun on db, as the WithTx function does,
+// passing it a copy of b whose child builders run with the transaction.
+//
+// Ex:
+//
+//	psql := StatementBuilder.PlaceholderFormat(Dollar)
+//	err := psql.WithTx(ctx, db, nil, func(sb statementBuilderType) error {
+//		_, err := sb.Delete("sessions").Where(Eq{"user_id": id}).ExecContext(ctx)
+//		return err
+//	})
+func (b statementBuilderType) WithTx(ctx context.Context, db TxBeginner, opts *TxOptions, fn func(sb statementBuilderType) error) error {
+	return WithTx(ctx, db, opts, func(tx RunnerContext) error {
+		return fn(b.RunWith(tx))
+	})
+}
+
+// ExponentialBackoff returns a TxOptions.Backoff doubling the wait from base
+// on every retry, up to maxWait, with up to 50% of random jitter so that
+// conflicting transactions don't retry in lockstep.
+func ExponentialBackoff(base, maxWait time.Duration) func(retry int) time.Duration {
+	return func(retry int) time.Duration {
+		d := base
+		for i := 1; i < retry && d < maxWait; i++ {
+			d *= 2
+		}
+		if d > maxWait {
+			d = maxWait
+		}
+		if d <= 0 {
+			return 0
+		}
+		return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+}
+
+// retryableSQLStates are the SQLSTATE codes of the errors that go away when a
+// transaction is run again: serialization failures and deadlocks.
+var retryableSQLStates = map[string]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected (Postgres)
+}
+
+// mysqlDeadlock is the MySQL error number of deadlocks (ER_LOCK_DEADLOCK).
+const mysqlDeadlock = 1213
+
+// IsRetryableTxError reports whether err, or any error it wraps, is a
+// serialization failure or a deadlock. It recognizes the errors of the common
+// drivers without importing them: errors with a SQLState() string method
+// (pgx), a string Code field (lib/pq) or an unsigned Number field
+// (go-sql-driver/mysql).
+func IsRetryableTxError(err error) bool {
+	for _, e := range unwrapAll(err) {
+		if s, ok := e.(interface{ SQLState() string }); ok && retryableSQLStates[s.SQLState()] {
+			return true
+		}
+
+		v := reflect.ValueOf(e)
+		for v.Kind() == reflect.Ptr && !v.IsNil() {
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			continue
+		}
+		if code := v.FieldByName("Code"); code.IsValid() && code.Kind() == reflect.String &&
+			retryableSQLStates[code.String()] {
+			return true
+		}
+		if number := v.FieldByName("Number"); number.IsValid() && number.CanUint() &&
+			number.Uint() == mysqlDeadlock {
+			return true
+		}
+	}
+	return false
+}
+
+// unwrapAll returns err and every error it wraps, depth first.
+func unwrapAll(err error) []error {
+	if err == nil {
+		return nil
+	}
+	errs := []error{err}
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		errs = append(errs, unwrapAll(u.Unwrap())...)
+	case interface{ Unwrap() []error }:
+		for _, e := range u.Unwrap() {
+			errs = append(errs, unwrapAll(e)...)
+		}
+	}
+	return errs
+}
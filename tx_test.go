@@ -0,0 +1,238 @@
+package squirrel2
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sqlStateError mimics the errors of pgx.
+type sqlStateError struct{ code string }
+
+func (e *sqlStateError) Error() string    { return "sqlstate " + e.code }
+func (e *sqlStateError) SQLState() string { return e.code }
+
+// pqError mimics the errors of lib/pq.
+type pqError struct{ Code pqErrorCode }
+
+type pqErrorCode string
+
+func (e *pqError) Error() string { return "pq: " + string(e.Code) }
+
+// mysqlError mimics the errors of go-sql-driver/mysql.
+type mysqlError struct{ Number uint16 }
+
+func (e *mysqlError) Error() string { return fmt.Sprintf("Error %d", e.Number) }
+
+func noBackoff(int) time.Duration { return 0 }
+
+func TestWithTxCommit(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	err := WithTx(context.Background(), db, nil, func(tx RunnerContext) error {
+		_, err := Update("t").Set("x", 1).RunWith(tx).ExecContext(context.Background())
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"BEGIN", "UPDATE t SET x = ?", "COMMIT"}, fake.statements())
+}
+
+func TestWithTxRollback(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	expectedErr := errors.New("nope")
+	err := WithTx(context.Background(), db, nil, func(tx RunnerContext) error {
+		return expectedErr
+	})
+	assert.Equal(t, expectedErr, err)
+	assert.Equal(t, []string{"BEGIN", "ROLLBACK"}, fake.statements())
+}
+
+func TestWithTxPanic(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = WithTx(context.Background(), db, nil, func(tx RunnerContext) error {
+			panic("boom")
+		})
+	})
+	assert.Equal(t, []string{"BEGIN", "ROLLBACK"}, fake.statements())
+}
+
+func TestWithTxRetry(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	commits := 0
+	fake.execErr = func(query string) error {
+		if query == "COMMIT" {
+			if commits++; commits == 1 {
+				return &sqlStateError{"40001"}
+			}
+		}
+		return nil
+	}
+
+	runs := 0
+	var retries []int
+	opts := &TxOptions{Backoff: func(retry int) time.Duration {
+		retries = append(retries, retry)
+		return 0
+	}}
+	err := WithTx(context.Background(), db, opts, func(tx RunnerContext) error {
+		runs++
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, runs)
+	assert.Equal(t, []int{1}, retries)
+	assert.Equal(t, []string{"BEGIN", "COMMIT", "BEGIN", "COMMIT"}, fake.statements())
+}
+
+func TestWithTxRetryExhausted(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	runs := 0
+	deadlock := &mysqlError{Number: 1213}
+	err := WithTx(context.Background(), db, &TxOptions{MaxAttempts: 2, Backoff: noBackoff},
+		func(tx RunnerContext) error {
+			runs++
+			return fmt.Errorf("transfer: %w", deadlock)
+		})
+	assert.ErrorIs(t, err, deadlock)
+	assert.Equal(t, 2, runs)
+	assert.Equal(t, []string{"BEGIN", "ROLLBACK", "BEGIN", "ROLLBACK"}, fake.statements())
+}
+
+func TestWithTxCustomClassifier(t *testing.T) {
+	db, _ := newFakeDB(fakeResult{})
+	busy := errors.New("database is locked")
+	runs := 0
+	opts := &TxOptions{
+		Backoff:     noBackoff,
+		IsRetryable: func(err error) bool { return errors.Is(err, busy) },
+	}
+	err := WithTx(context.Background(), db, opts, func(tx RunnerContext) error {
+		if runs++; runs < 3 {
+			return busy
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, runs)
+
+	runs = 0
+	err = WithTx(context.Background(), db, opts, func(tx RunnerContext) error {
+		runs++
+		return &sqlStateError{"40001"}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, runs)
+}
+
+func TestWithTxContextDone(t *testing.T) {
+	db, _ := newFakeDB(fakeResult{})
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := 0
+	err := WithTx(ctx, db, &TxOptions{Backoff: func(int) time.Duration { return time.Hour }},
+		func(tx RunnerContext) error {
+			runs++
+			cancel()
+			return &sqlStateError{"40P01"}
+		})
+	assert.Equal(t, &sqlStateError{"40P01"}, err)
+	assert.Equal(t, 1, runs)
+}
+
+func TestStatementBuilderWithTx(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	sb := StatementBuilder.PlaceholderFormat(Dollar)
+	err := sb.WithTx(context.Background(), db, nil, func(sb statementBuilderType) error {
+		_, err := sb.Delete("sessions").Where(Eq{"user_id": 1}).ExecContext(context.Background())
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"BEGIN", "DELETE FROM sessions WHERE user_id = $1", "COMMIT"}, fake.statements())
+}
+
+func TestIsRetryableTxError(t *testing.T) {
+	assert.True(t, IsRetryableTxError(&sqlStateError{"40001"}))
+	assert.True(t, IsRetryableTxError(&pqError{Code: "40P01"}))
+	assert.True(t, IsRetryableTxError(fmt.Errorf("wrapped: %w", &mysqlError{Number: 1213})))
+	assert.True(t, IsRetryableTxError(errors.Join(errors.New("x"), &pqError{Code: "40001"})))
+
+	assert.False(t, IsRetryableTxError(nil))
+	assert.False(t, IsRetryableTxError(errors.New("40001")))
+	assert.False(t, IsRetryableTxError(&sqlStateError{"23505"}))
+	assert.False(t, IsRetryableTxError(&mysqlError{Number: 1062}))
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	for retry, limit := range map[int]time.Duration{1: 10, 2: 20, 3: 40, 4: 50, 10: 50} {
+		d := backoff(retry)
+		assert.True(t, d >= limit*time.Millisecond/2 && d <= limit*time.Millisecond, "retry %d: %s", retry, d)
+	}
+	assert.Equal(t, time.Duration(0), ExponentialBackoff(0, time.Second)(3))
+}
+
+func TestTxSavepoints(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	expectedErr := errors.New("nope")
+	err := WithTx(context.Background(), db, nil, func(tx RunnerContext) error {
+		outer := tx.(*Tx)
+		assert.NoError(t, outer.WithTx(context.Background(), func(tx RunnerContext) error {
+			_, err := Insert("t").Values(1).RunWith(tx).ExecContext(context.Background())
+			return err
+		}))
+		assert.Equal(t, expectedErr, outer.WithTx(context.Background(), func(tx RunnerContext) error {
+			return tx.(*Tx).WithTx(context.Background(), func(RunnerContext) error {
+				return expectedErr
+			})
+		}))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"BEGIN",
+		"SAVEPOINT sp_1", "INSERT INTO t VALUES (?)", "RELEASE SAVEPOINT sp_1",
+		"SAVEPOINT sp_2", "SAVEPOINT sp_3",
+		"ROLLBACK TO SAVEPOINT sp_3", "RELEASE SAVEPOINT sp_3",
+		"ROLLBACK TO SAVEPOINT sp_2", "RELEASE SAVEPOINT sp_2",
+		"COMMIT",
+	}, fake.statements())
+}
+
+func TestTxWithTxRolledBackByFn(t *testing.T) {
+	db, fake := newFakeDB(fakeResult{})
+	expectedErr := errors.New("nope")
+	err := WithTx(context.Background(), db, nil, func(tx RunnerContext) error {
+		err := tx.(*Tx).WithTx(context.Background(), func(tx RunnerContext) error {
+			assert.NoError(t, tx.(*Tx).Rollback())
+			return expectedErr
+		})
+		assert.Equal(t, expectedErr, err)
+		assert.NoError(t, tx.(*Tx).Rollback())
+		return expectedErr
+	})
+	assert.Equal(t, expectedErr, err)
+	assert.Equal(t, []string{
+		"BEGIN",
+		"SAVEPOINT sp_1", "ROLLBACK TO SAVEPOINT sp_1", "RELEASE SAVEPOINT sp_1",
+		"ROLLBACK",
+	}, fake.statements())
+}
+
+func TestTxSavepointDone(t *testing.T) {
+	db, _ := newFakeDB(fakeResult{})
+	sqlTx, err := db.Begin()
+	assert.NoError(t, err)
+	tx := NewTx(sqlTx)
+
+	sp, err := tx.Begin(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, sp.Commit())
+	assert.Equal(t, sql.ErrTxDone, sp.Rollback())
+	_, err = sp.Begin(context.Background())
+	assert.Equal(t, sql.ErrTxDone, err)
+	assert.NoError(t, tx.Rollback())
+}
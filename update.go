@@ -2,16 +2,21 @@ package squirrel2
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type updateData struct {
 	PlaceholderFormat PlaceholderFormat
 	RunWith           BaseRunner
+	Timeout           time.Duration
+	Tags              sqlTags
+	ExpectRollback    bool
 	Prefixes          []Sqlizer
 	Table             safeString
 	SetClauses        []setClause
@@ -32,6 +37,9 @@ func (d *updateData) Exec() (sql.Result, error) {
 	if d.RunWith == nil {
 		return nil, ErrRunnerNotSet
 	}
+	if d.Timeout > 0 {
+		return d.ExecContext(context.Background())
+	}
 	return ExecWith(d.RunWith, d)
 }
 
@@ -39,6 +47,9 @@ func (d *updateData) Query() (*sql.Rows, error) {
 	if d.RunWith == nil {
 		return nil, ErrRunnerNotSet
 	}
+	if d.Timeout > 0 {
+		return d.QueryContext(context.Background())
+	}
 	return QueryWith(d.RunWith, d)
 }
 
@@ -46,6 +57,9 @@ func (d *updateData) QueryRow() RowScanner {
 	if d.RunWith == nil {
 		return &Row{err: ErrRunnerNotSet}
 	}
+	if d.Timeout > 0 {
+		return d.QueryRowContext(context.Background())
+	}
 	queryRower, ok := d.RunWith.(QueryRower)
 	if !ok {
 		return &Row{err: ErrRunnerNotQueryRunner}
@@ -54,6 +68,16 @@ func (d *updateData) QueryRow() RowScanner {
 }
 
 func (d *updateData) ToSql() (sqlStr string, args []interface{}, err error) {
+	sqlStr, args, err = d.toSqlRaw()
+	if err != nil {
+		return
+	}
+
+	sqlStr, args, err = replacePlaceholders(d.PlaceholderFormat, sqlStr, args)
+	return
+}
+
+func (d *updateData) toSqlRaw() (sqlStr string, args []interface{}, err error) {
 	if len(d.Table) == 0 {
 		err = fmt.Errorf("update statements must specify a table")
 		return
@@ -82,7 +106,7 @@ func (d *updateData) ToSql() (sqlStr string, args []interface{}, err error) {
 	for i, setClause := range d.SetClauses {
 		var valSql string
 		if vs, ok := setClause.value.(Sqlizer); ok {
-			vsql, vargs, err := vs.ToSql()
+			vsql, vargs, err := nestedToSql(vs)
 			if err != nil {
 				return "", nil, err
 			}
@@ -144,7 +168,8 @@ func (d *updateData) ToSql() (sqlStr string, args []interface{}, err error) {
 		}
 	}
 
-	sqlStr, err = d.PlaceholderFormat.ReplacePlaceholders(sql.String())
+	// The tags go with the statement, even when it is nested in another.
+	sqlStr = d.Tags.apply(sql.String())
 	return
 }
 
@@ -160,6 +185,8 @@ func UpdateBuilder(b statementBuilderType) updateBuilder {
 		data: updateData{
 			PlaceholderFormat: b.placeholderFormat,
 			RunWith:           b.runWith,
+			Timeout:           b.timeout,
+			Tags:              b.tags,
 			WhereParts:        b.whereParts,
 			Prefixes:          make([]Sqlizer, 0),
 			SetClauses:        make([]setClause, 0),
@@ -192,6 +219,38 @@ func (b updateBuilder) RunWith(runner BaseRunner) updateBuilder {
 	return b
 }
 
+// Timeout sets the time the statement may run for.
+//
+// See SelectBuilder.Timeout for more information.
+func (b updateBuilder) Timeout(d time.Duration) updateBuilder {
+	b.data.Timeout = d
+	return b
+}
+
+// Tag adds a tag to the comment of the statement.
+//
+// See SelectBuilder.Tag for more information.
+func (b updateBuilder) Tag(key, value string) updateBuilder {
+	b.data.Tags = b.data.Tags.with(key, value)
+	return b
+}
+
+// TagPosition sets where the comment holding the tags goes.
+//
+// See SelectBuilder.TagPosition for more information.
+func (b updateBuilder) TagPosition(p TagPosition) updateBuilder {
+	b.data.Tags.position = p
+	return b
+}
+
+// RollbackOnUnexpected makes ExecExpect and ExecExpectRange roll back the
+// transaction or savepoint they run with (e.g. a *Tx or *sql.Tx) when the
+// statement affects an unexpected number of rows.
+func (b updateBuilder) RollbackOnUnexpected() updateBuilder {
+	b.data.ExpectRollback = true
+	return b
+}
+
 // Exec builds and Execs the query with the Runner set by RunWith.
 func (b updateBuilder) Exec() (sql.Result, error) {
 	return b.data.Exec()
@@ -216,6 +275,10 @@ func (b updateBuilder) ToSql() (string, []interface{}, error) {
 	return b.data.ToSql()
 }
 
+func (b updateBuilder) toSqlRaw() (string, []interface{}, error) {
+	return b.data.toSqlRaw()
+}
+
 // MustSql builds the query into a SQL string and bound args.
 // It panics if there are any errors.
 func (b updateBuilder) MustSql() (string, []interface{}) {
@@ -276,7 +339,7 @@ func (b updateBuilder) From(from safeString) updateBuilder {
 
 // FromSelect sets a subquery into the FROM clause of the query.
 func (b updateBuilder) FromSelect(from selectBuilder, alias safeString) updateBuilder {
-	b.data.From = Alias(from.PlaceholderFormat(Question), alias)
+	b.data.From = Alias(from, alias)
 	return b
 }
 
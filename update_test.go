@@ -105,3 +105,21 @@ func TestUpdateBuilderFromSelect(t *testing.T) {
 			"WHERE employees.account_id = subquery.id"
 	assert.Equal(t, expectedSql, sql)
 }
+
+func TestUpdateBuilderNestedDollar(t *testing.T) {
+	sub := Select("max(v)").From("b").Where(Eq{"k": 1}).PlaceholderFormat(Dollar)
+	b := Update("a").
+		Set("x", 0).
+		Set("y", sub).
+		FromSelect(sub, "s").
+		Where(Eq{"z": 2}).
+		PlaceholderFormat(Dollar)
+
+	sql, args, err := b.ToSql()
+	assert.NoError(t, err)
+
+	expectedSql := "UPDATE a SET x = $1, y = (SELECT max(v) FROM b WHERE k = $2) " +
+		"FROM (SELECT max(v) FROM b WHERE k = $3) AS s WHERE z = $4"
+	assert.Equal(t, expectedSql, sql)
+	assert.Equal(t, []interface{}{0, 1, 1, 2}, args)
+}
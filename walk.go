@@ -0,0 +1,509 @@
+package squirrel2
+
+import "fmt"
+
+// Clause is the part of its parent a Node is in.
+type Clause int
+
+const (
+	// ClauseRoot is the clause of the root of a tree, which has no parent.
+	ClauseRoot Clause = iota
+	// ClauseOperand is the clause of the operands of expressions: those of
+	// And, Or and ConcatExpr, the Sqlizer args of Expr, the expressions of
+	// Alias and ExprIf, and the parts of Case.
+	ClauseOperand
+	ClausePrefix
+	ClauseColumns
+	ClauseFrom
+	ClauseJoin
+	ClauseWhere
+	ClauseGroupBy
+	ClauseHaving
+	ClauseOrderBy
+	ClauseSuffix
+	// ClauseInto is the table of an INSERT statement.
+	ClauseInto
+	// ClauseValues holds the Sqlizer values of an INSERT statement.
+	ClauseValues
+	// ClauseSelect is the SELECT statement of an INSERT ... SELECT statement.
+	ClauseSelect
+	// ClauseTable is the table of an UPDATE statement.
+	ClauseTable
+	// ClauseSet holds the Sqlizer values of the SET clause of an UPDATE
+	// statement.
+	ClauseSet
+	// ClauseStatements holds the statements of a Batch.
+	ClauseStatements
+)
+
+var clauseNames = [...]string{
+	ClauseRoot:       "root",
+	ClauseOperand:    "operand",
+	ClausePrefix:     "prefix",
+	ClauseColumns:    "columns",
+	ClauseFrom:       "from",
+	ClauseJoin:       "join",
+	ClauseWhere:      "where",
+	ClauseGroupBy:    "group by",
+	ClauseHaving:     "having",
+	ClauseOrderBy:    "order by",
+	ClauseSuffix:     "suffix",
+	ClauseInto:       "into",
+	ClauseValues:     "values",
+	ClauseSelect:     "select",
+	ClauseTable:      "table",
+	ClauseSet:        "set",
+	ClauseStatements: "statements",
+}
+
+func (c Clause) String() string {
+	if c >= 0 && int(c) < len(clauseNames) {
+		return clauseNames[c]
+	}
+	return fmt.Sprintf("Clause(%d)", int(c))
+}
+
+// Node is a node of a tree of Sqlizers, as visited by Walk and Rewrite.
+//
+// The children of the builders of this package are the Sqlizers of their
+// clauses, including the strings of their FROM, INTO, table, GROUP BY and
+// ORDER BY clauses, and of the columns of INSERT statements, as safeStrings.
+// Keywords, e.g. those set by Options, LIMIT and OFFSET aren't nodes. The
+// children of And, Or, ConcatExpr, Expr, Alias, ExprIf and Case are their
+// operands, those of a Batch its statements, and the child of an OrderByTerm
+// is its Expr, as a safeString. Other Sqlizers, e.g. Eq, have no children.
+type Node struct {
+	// Sqlizer is the node.
+	Sqlizer Sqlizer
+	// Parent is the node holding Sqlizer, or nil for the root. For Rewrite,
+	// it's the parent as it was before its children were rewritten.
+	Parent Sqlizer
+	// Clause is the part of Parent Sqlizer is in.
+	Clause Clause
+}
+
+// Walk traverses the tree of root in depth-first order: it calls fn with each
+// node, then walks the children of the node if fn returns true.
+//
+// Ex:
+//
+//	// Lists the columns compared by Eq in the WHERE clauses of q.
+//	Walk(q, func(n Node) bool {
+//		if eq, ok := n.Sqlizer.(Eq); ok && n.Clause == ClauseWhere {
+//			for column := range eq {
+//				columns = append(columns, column)
+//			}
+//		}
+//		return true
+//	})
+func Walk(root Sqlizer, fn func(n Node) bool) {
+	walk(Node{Sqlizer: root}, fn)
+}
+
+func walk(n Node, fn func(n Node) bool) {
+	if !fn(n) {
+		return
+	}
+	// Only the visits are needed, not the copy of n.Sqlizer.
+	_, _ = rewriteChildren(n.Sqlizer, func(child Sqlizer, clause Clause) (Sqlizer, error) {
+		walk(Node{Sqlizer: child, Parent: n.Sqlizer, Clause: clause}, fn)
+		return child, nil
+	})
+}
+
+// Rewrite returns a copy of the tree of root where each node is replaced by
+// what fn returns for it. Nodes are rewritten bottom-up: fn is called with a
+// node once its children are rewritten. Returning n.Sqlizer keeps the node.
+// The tree of root isn't changed, consistently with the builders.
+//
+// Returning nil removes the node from its parent, if it's in a list, e.g. the
+// WHERE parts of a statement or the operands of And, or in an optional
+// clause, e.g. the FROM clause of a SELECT statement. Rewrite returns an error
+// for nodes that can't be removed, or replaced by a Sqlizer of a type their
+// clause doesn't accept, e.g. a table by something else than a safeString.
+//
+// Ex:
+//
+//	// Renames the users table, and only selects the rows of tenant.
+//	q, err := Rewrite(q, func(n Node) (Sqlizer, error) {
+//		if n.Sqlizer == SafeString("users") && (n.Clause == ClauseFrom || n.Clause == ClauseTable) {
+//			return SafeString("tenant_users"), nil
+//		}
+//		if s, ok := AddWhere(n.Sqlizer, Eq{"tenant_id": tenant}); ok {
+//			return s, nil
+//		}
+//		return n.Sqlizer, nil
+//	})
+func Rewrite(root Sqlizer, fn func(n Node) (Sqlizer, error)) (Sqlizer, error) {
+	return rewrite(Node{Sqlizer: root}, fn)
+}
+
+func rewrite(n Node, fn func(n Node) (Sqlizer, error)) (Sqlizer, error) {
+	s, err := rewriteChildren(n.Sqlizer, func(child Sqlizer, clause Clause) (Sqlizer, error) {
+		return rewrite(Node{Sqlizer: child, Parent: n.Sqlizer, Clause: clause}, fn)
+	})
+	if err != nil {
+		return nil, err
+	}
+	n.Sqlizer = s
+	return fn(n)
+}
+
+// AddWhere returns s with preds added to its WHERE clause, if s is a SELECT,
+// UPDATE or DELETE builder. It's meant for Rewrite, whose callers can't use
+// the Where methods of the builders of the nodes they get.
+func AddWhere(s Sqlizer, preds ...Sqlizer) (Sqlizer, bool) {
+	switch b := s.(type) {
+	case selectBuilder:
+		b.data.WhereParts = append(b.data.WhereParts[:len(b.data.WhereParts):len(b.data.WhereParts)], preds...)
+		return b, true
+	case updateBuilder:
+		b.data.WhereParts = append(b.data.WhereParts[:len(b.data.WhereParts):len(b.data.WhereParts)], preds...)
+		return b, true
+	case deleteBuilder:
+		b.data.WhereParts = append(b.data.WhereParts[:len(b.data.WhereParts):len(b.data.WhereParts)], preds...)
+		return b, true
+	}
+	return s, false
+}
+
+// Walk traverses the tree of the query. See Walk for more information.
+func (b selectBuilder) Walk(fn func(n Node) bool) {
+	Walk(b, fn)
+}
+
+// Rewrite returns a copy of the query rewritten by fn, which must keep it a
+// SELECT builder. See Rewrite for more information.
+func (b selectBuilder) Rewrite(fn func(n Node) (Sqlizer, error)) (selectBuilder, error) {
+	s, err := Rewrite(b, fn)
+	if err != nil {
+		return b, err
+	}
+	rewritten, ok := s.(selectBuilder)
+	if !ok {
+		return b, fmt.Errorf("cannot replace a select statement with %T", s)
+	}
+	return rewritten, nil
+}
+
+// Walk traverses the tree of the query. See Walk for more information.
+func (b insertBuilder) Walk(fn func(n Node) bool) {
+	Walk(b, fn)
+}
+
+// Rewrite returns a copy of the query rewritten by fn, which must keep it an
+// INSERT builder. See Rewrite for more information.
+func (b insertBuilder) Rewrite(fn func(n Node) (Sqlizer, error)) (insertBuilder, error) {
+	s, err := Rewrite(b, fn)
+	if err != nil {
+		return b, err
+	}
+	rewritten, ok := s.(insertBuilder)
+	if !ok {
+		return b, fmt.Errorf("cannot replace an insert statement with %T", s)
+	}
+	return rewritten, nil
+}
+
+// Walk traverses the tree of the query. See Walk for more information.
+func (b updateBuilder) Walk(fn func(n Node) bool) {
+	Walk(b, fn)
+}
+
+// Rewrite returns a copy of the query rewritten by fn, which must keep it an
+// UPDATE builder. See Rewrite for more information.
+func (b updateBuilder) Rewrite(fn func(n Node) (Sqlizer, error)) (updateBuilder, error) {
+	s, err := Rewrite(b, fn)
+	if err != nil {
+		return b, err
+	}
+	rewritten, ok := s.(updateBuilder)
+	if !ok {
+		return b, fmt.Errorf("cannot replace an update statement with %T", s)
+	}
+	return rewritten, nil
+}
+
+// Walk traverses the tree of the query. See Walk for more information.
+func (b deleteBuilder) Walk(fn func(n Node) bool) {
+	Walk(b, fn)
+}
+
+// Rewrite returns a copy of the query rewritten by fn, which must keep it a
+// DELETE builder. See Rewrite for more information.
+func (b deleteBuilder) Rewrite(fn func(n Node) (Sqlizer, error)) (deleteBuilder, error) {
+	s, err := Rewrite(b, fn)
+	if err != nil {
+		return b, err
+	}
+	rewritten, ok := s.(deleteBuilder)
+	if !ok {
+		return b, fmt.Errorf("cannot replace a delete statement with %T", s)
+	}
+	return rewritten, nil
+}
+
+// childVisitor is called with each child of a node, and returns what replaces
+// it, or nil to remove it.
+type childVisitor func(child Sqlizer, clause Clause) (Sqlizer, error)
+
+// rewriteChildren returns a copy of s whose children are replaced by what
+// visit returns for them.
+func rewriteChildren(s Sqlizer, visit childVisitor) (Sqlizer, error) {
+	var err error
+	switch n := s.(type) {
+	case selectBuilder:
+		d := &n.data
+		if d.Prefixes, err = visitList(d.Prefixes, ClausePrefix, visit); err != nil {
+			return nil, err
+		}
+		if d.Columns, err = visitList(d.Columns, ClauseColumns, visit); err != nil {
+			return nil, err
+		}
+		if d.From != nil {
+			if d.From, err = visit(d.From, ClauseFrom); err != nil {
+				return nil, err
+			}
+		}
+		if d.Joins, err = visitList(d.Joins, ClauseJoin, visit); err != nil {
+			return nil, err
+		}
+		if d.WhereParts, err = visitList(d.WhereParts, ClauseWhere, visit); err != nil {
+			return nil, err
+		}
+		if d.GroupBys, err = visitSafeList(d.GroupBys, ClauseGroupBy, visit); err != nil {
+			return nil, err
+		}
+		if d.HavingParts, err = visitList(d.HavingParts, ClauseHaving, visit); err != nil {
+			return nil, err
+		}
+		if d.OrderByParts, err = visitList(d.OrderByParts, ClauseOrderBy, visit); err != nil {
+			return nil, err
+		}
+		if d.Suffixes, err = visitList(d.Suffixes, ClauseSuffix, visit); err != nil {
+			return nil, err
+		}
+		return n, nil
+
+	case insertBuilder:
+		d := &n.data
+		if d.Prefixes, err = visitList(d.Prefixes, ClausePrefix, visit); err != nil {
+			return nil, err
+		}
+		if d.Into, err = visitSafe(d.Into, ClauseInto, visit); err != nil {
+			return nil, err
+		}
+		if d.Columns, err = visitSafeList(d.Columns, ClauseColumns, visit); err != nil {
+			return nil, err
+		}
+		values := make([][]interface{}, len(d.Values))
+		for i, row := range d.Values {
+			if values[i], err = visitValues(row, ClauseValues, visit); err != nil {
+				return nil, err
+			}
+		}
+		d.Values = values
+		if d.Select != nil {
+			s, err := visit(*d.Select, ClauseSelect)
+			if err != nil {
+				return nil, err
+			}
+			sb, ok := s.(selectBuilder)
+			if !ok {
+				return nil, fmt.Errorf("cannot put %T in the %s clause, it needs a select statement", s, ClauseSelect)
+			}
+			d.Select = &sb
+		}
+		if d.Suffixes, err = visitList(d.Suffixes, ClauseSuffix, visit); err != nil {
+			return nil, err
+		}
+		return n, nil
+
+	case updateBuilder:
+		d := &n.data
+		if d.Prefixes, err = visitList(d.Prefixes, ClausePrefix, visit); err != nil {
+			return nil, err
+		}
+		if d.Table, err = visitSafe(d.Table, ClauseTable, visit); err != nil {
+			return nil, err
+		}
+		setClauses := make([]setClause, len(d.SetClauses))
+		for i, set := range d.SetClauses {
+			value, err := visitValues([]interface{}{set.value}, ClauseSet, visit)
+			if err != nil {
+				return nil, err
+			}
+			setClauses[i] = setClause{column: set.column, value: value[0]}
+		}
+		d.SetClauses = setClauses
+		if d.From != nil {
+			if d.From, err = visit(d.From, ClauseFrom); err != nil {
+				return nil, err
+			}
+		}
+		if d.WhereParts, err = visitList(d.WhereParts, ClauseWhere, visit); err != nil {
+			return nil, err
+		}
+		if d.OrderBys, err = visitSafeList(d.OrderBys, ClauseOrderBy, visit); err != nil {
+			return nil, err
+		}
+		if d.Suffixes, err = visitList(d.Suffixes, ClauseSuffix, visit); err != nil {
+			return nil, err
+		}
+		return n, nil
+
+	case deleteBuilder:
+		d := &n.data
+		if d.Prefixes, err = visitList(d.Prefixes, ClausePrefix, visit); err != nil {
+			return nil, err
+		}
+		if d.From, err = visitSafe(d.From, ClauseFrom, visit); err != nil {
+			return nil, err
+		}
+		if d.WhereParts, err = visitList(d.WhereParts, ClauseWhere, visit); err != nil {
+			return nil, err
+		}
+		if d.OrderBys, err = visitSafeList(d.OrderBys, ClauseOrderBy, visit); err != nil {
+			return nil, err
+		}
+		if d.Suffixes, err = visitList(d.Suffixes, ClauseSuffix, visit); err != nil {
+			return nil, err
+		}
+		return n, nil
+
+	case caseBuilder:
+		d := &n.data
+		if d.What != nil {
+			if d.What, err = visit(d.What, ClauseOperand); err != nil {
+				return nil, err
+			}
+		}
+		whenParts := make([]whenPart, len(d.WhenParts))
+		for i, p := range d.WhenParts {
+			if whenParts[i].when, err = visitRequired(p.when, ClauseOperand, visit); err != nil {
+				return nil, err
+			}
+			if whenParts[i].then, err = visitRequired(p.then, ClauseOperand, visit); err != nil {
+				return nil, err
+			}
+		}
+		d.WhenParts = whenParts
+		if d.Else != nil {
+			if d.Else, err = visit(d.Else, ClauseOperand); err != nil {
+				return nil, err
+			}
+		}
+		return n, nil
+
+	case And:
+		operands, err := visitList(n, ClauseOperand, visit)
+		return And(operands), err
+	case Or:
+		operands, err := visitList(n, ClauseOperand, visit)
+		return Or(operands), err
+	case concatExpr:
+		parts, err := visitList(n, ClauseOperand, visit)
+		return concatExpr(parts), err
+	case expr:
+		// Removing an arg would shift the others to the wrong placeholders.
+		n.args, err = visitValues(n.args, ClauseOperand, visit)
+		return n, err
+	case aliasExpr:
+		n.expr, err = visitRequired(n.expr, ClauseOperand, visit)
+		return n, err
+	case exprIf:
+		n.expression, err = visitRequired(n.expression, ClauseOperand, visit)
+		return n, err
+	case OrderByTerm:
+		n.Expr, err = visitSafe(n.Expr, ClauseOperand, visit)
+		return n, err
+	case Batch:
+		n.statements, err = visitList(n.statements, ClauseStatements, visit)
+		return n, err
+	}
+	return s, nil
+}
+
+// visitList returns a copy of list where each Sqlizer is replaced by what
+// visit returns for it, without the nils.
+func visitList(list []Sqlizer, clause Clause, visit childVisitor) ([]Sqlizer, error) {
+	if list == nil {
+		return nil, nil
+	}
+	rewritten := make([]Sqlizer, 0, len(list))
+	for _, s := range list {
+		s, err := visit(s, clause)
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			rewritten = append(rewritten, s)
+		}
+	}
+	return rewritten, nil
+}
+
+// visitSafeList is visitList for a list of safeStrings, which visit can only
+// replace by safeStrings.
+func visitSafeList(list []safeString, clause Clause, visit childVisitor) ([]safeString, error) {
+	if list == nil {
+		return nil, nil
+	}
+	rewritten := make([]safeString, 0, len(list))
+	for _, s := range list {
+		r, err := visit(s, clause)
+		if err != nil {
+			return nil, err
+		}
+		if r == nil {
+			continue
+		}
+		safe, ok := r.(safeString)
+		if !ok {
+			return nil, fmt.Errorf("cannot put %T in the %s clause, it needs a safeString", r, clause)
+		}
+		rewritten = append(rewritten, safe)
+	}
+	return rewritten, nil
+}
+
+// visitSafe returns what visit returns for s, which must be a safeString.
+func visitSafe(s safeString, clause Clause, visit childVisitor) (safeString, error) {
+	r, err := visitRequired(s, clause, visit)
+	if err != nil {
+		return "", err
+	}
+	safe, ok := r.(safeString)
+	if !ok {
+		return "", fmt.Errorf("cannot put %T in the %s clause, it needs a safeString", r, clause)
+	}
+	return safe, nil
+}
+
+// visitRequired returns what visit returns for s, which can't be nil.
+func visitRequired(s Sqlizer, clause Clause, visit childVisitor) (Sqlizer, error) {
+	r, err := visit(s, clause)
+	if err == nil && r == nil {
+		err = fmt.Errorf("cannot remove %T from the %s clause", s, clause)
+	}
+	return r, err
+}
+
+// visitValues returns a copy of values where the Sqlizers are replaced by what
+// visit returns for them. The other values aren't visited.
+func visitValues(values []interface{}, clause Clause, visit childVisitor) ([]interface{}, error) {
+	if values == nil {
+		return nil, nil
+	}
+	rewritten := make([]interface{}, len(values))
+	for i, v := range values {
+		if s, ok := v.(Sqlizer); ok {
+			var err error
+			if v, err = visitRequired(s, clause, visit); err != nil {
+				return nil, err
+			}
+		}
+		rewritten[i] = v
+	}
+	return rewritten, nil
+}
@@ -0,0 +1,216 @@
+package squirrel2
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalk(t *testing.T) {
+	sub := Select("id").From("admins").Where(Eq{"active": true})
+	q := Select("id", "name").
+		Column(Alias(Case(SafeString("status")).When(Expr("1"), Expr("'on'")).Else(Expr("'off'")), "state")).
+		From("users").
+		Join("emails USING (id)").
+		Where(Or{Eq{"role": "admin"}, Expr("id IN (?)", sub)}).
+		GroupBy("name").
+		OrderBy("name")
+
+	var visited []string
+	var columns []safeString
+	Walk(q, func(n Node) bool {
+		visited = append(visited, n.Clause.String())
+		if eq, ok := n.Sqlizer.(Eq); ok {
+			for column := range eq {
+				columns = append(columns, column)
+			}
+		}
+		return true
+	})
+	assert.Equal(t, []string{
+		"root",
+		"columns", "columns", "columns", "operand", // Alias
+		"operand", "operand", "operand", "operand", // Case
+		"from", "join",
+		"where", "operand", "operand", "operand", // Or, Expr and its subquery
+		"columns", "from", "where", // the subquery
+		"group by", "order by",
+	}, visited)
+	assert.Equal(t, []safeString{"role", "active"}, columns)
+
+	// Returning false skips the children.
+	visited = nil
+	q.Walk(func(n Node) bool {
+		visited = append(visited, n.Clause.String())
+		return n.Clause == ClauseRoot
+	})
+	assert.Equal(t, []string{"root", "columns", "columns", "columns", "from", "join", "where", "group by", "order by"}, visited)
+}
+
+func TestWalkParents(t *testing.T) {
+	eq := Eq{"x": 1}
+	and := And{eq}
+	q := Delete("t").Where(and)
+
+	var parents []Sqlizer
+	q.Walk(func(n Node) bool {
+		parents = append(parents, n.Parent)
+		return true
+	})
+	assert.Equal(t, []Sqlizer{nil, q, q, and}, parents)
+}
+
+func TestRewrite(t *testing.T) {
+	sub := Select("id").From("users").Where(Eq{"active": true})
+	q := Select("*").From("users").Where(Expr("id IN (?)", sub)).Where(Eq{"deleted": true})
+
+	rewritten, err := q.Rewrite(func(n Node) (Sqlizer, error) {
+		if n.Sqlizer == SafeString("users") && n.Clause == ClauseFrom {
+			return SafeString("tenant_users"), nil
+		}
+		if eq, ok := n.Sqlizer.(Eq); ok && eq["deleted"] != nil {
+			return nil, nil
+		}
+		if s, ok := AddWhere(n.Sqlizer, Eq{"tenant_id": 7}); ok {
+			return s, nil
+		}
+		return n.Sqlizer, nil
+	})
+	assert.NoError(t, err)
+	sql, args, err := rewritten.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"SELECT * FROM tenant_users WHERE id IN (SELECT id FROM tenant_users WHERE active = ? AND tenant_id = ?) AND tenant_id = ?",
+		sql)
+	assert.Equal(t, []interface{}{true, 7, 7}, args)
+
+	// The original query isn't changed.
+	sql, _, _ = q.ToSql()
+	assert.Equal(t, "SELECT * FROM users WHERE id IN (SELECT id FROM users WHERE active = ?) AND deleted = ?", sql)
+}
+
+func TestRewriteStatements(t *testing.T) {
+	rename := func(n Node) (Sqlizer, error) {
+		switch n.Sqlizer {
+		case SafeString("users"):
+			return SafeString("people"), nil
+		case SafeString("name"):
+			return SafeString("full_name"), nil
+		}
+		if s, ok := AddWhere(n.Sqlizer, Eq{"tenant_id": 7}); ok {
+			return s, nil
+		}
+		return n.Sqlizer, nil
+	}
+
+	ins, err := Insert("users").Columns("name", "created_at").Values("ann", Expr("NOW()")).Rewrite(rename)
+	assert.NoError(t, err)
+	sql, args, _ := ins.ToSql()
+	assert.Equal(t, "INSERT INTO people (full_name,created_at) VALUES (?,NOW())", sql)
+	assert.Equal(t, []interface{}{"ann"}, args)
+
+	ins, err = Insert("archive").Select(Select("name").From("users")).Rewrite(rename)
+	assert.NoError(t, err)
+	sql, _, _ = ins.ToSql()
+	assert.Equal(t, "INSERT INTO archive SELECT full_name FROM people WHERE tenant_id = ?", sql)
+
+	upd, err := Update("users").Set("name", Expr("UPPER(name)")).OrderBy("name").Rewrite(rename)
+	assert.NoError(t, err)
+	sql, _, _ = upd.ToSql()
+	assert.Equal(t, "UPDATE people SET name = UPPER(name) WHERE tenant_id = ? ORDER BY full_name", sql)
+
+	del, err := Delete("users").Where(And{ConcatExpr(SafeString("a = "), Expr("?", 1)), ExprIf(Expr("b"), true)}).Rewrite(rename)
+	assert.NoError(t, err)
+	sql, _, _ = del.ToSql()
+	assert.Equal(t, "DELETE FROM people WHERE (a = ? AND b) AND tenant_id = ?", sql)
+}
+
+func TestWalkBatch(t *testing.T) {
+	term := OrderByTerm{Field: "name", Expr: "users.name", Desc: true}
+	b := NewBatch(
+		Delete("users").Where(Eq{"id": 1}),
+		Select("id").From("users").OrderByClause(term),
+	)
+
+	var visited []string
+	Walk(b, func(n Node) bool {
+		visited = append(visited, n.Clause.String())
+		return true
+	})
+	assert.Equal(t, []string{
+		"root",
+		"statements", "from", "where",
+		"statements", "columns", "from", "order by", "operand", // the OrderByTerm and its Expr
+	}, visited)
+
+	// The statements of a batch are rewritten, and can be removed.
+	s, err := Rewrite(b, func(n Node) (Sqlizer, error) {
+		switch n.Sqlizer {
+		case SafeString("users"):
+			return SafeString("tenant_users"), nil
+		case SafeString("users.name"):
+			return SafeString("tenant_users.name"), nil
+		}
+		if _, ok := n.Sqlizer.(deleteBuilder); ok {
+			return nil, nil
+		}
+		return n.Sqlizer, nil
+	})
+	assert.NoError(t, err)
+	sql, _, err := s.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT id FROM tenant_users ORDER BY tenant_users.name DESC", sql)
+
+	_, err = Rewrite(term, func(n Node) (Sqlizer, error) {
+		if n.Clause == ClauseOperand {
+			return Expr("x"), nil
+		}
+		return n.Sqlizer, nil
+	})
+	assert.EqualError(t, err, "cannot put squirrel2.expr in the operand clause, it needs a safeString")
+}
+
+func TestRewriteErrors(t *testing.T) {
+	boom := errors.New("boom")
+	_, err := Select("*").From("t").Rewrite(func(n Node) (Sqlizer, error) {
+		if n.Clause == ClauseFrom {
+			return nil, boom
+		}
+		return n.Sqlizer, nil
+	})
+	assert.Equal(t, boom, err)
+
+	toExpr := func(clause Clause) func(n Node) (Sqlizer, error) {
+		return func(n Node) (Sqlizer, error) {
+			if n.Clause == clause {
+				return Expr("x"), nil
+			}
+			return n.Sqlizer, nil
+		}
+	}
+	_, err = Delete("t").Rewrite(toExpr(ClauseFrom))
+	assert.EqualError(t, err, "cannot put squirrel2.expr in the from clause, it needs a safeString")
+	_, err = Update("t").Set("x", 1).Rewrite(toExpr(ClauseTable))
+	assert.EqualError(t, err, "cannot put squirrel2.expr in the table clause, it needs a safeString")
+	_, err = Insert("t").Select(Select("x")).Rewrite(toExpr(ClauseSelect))
+	assert.EqualError(t, err, "cannot put squirrel2.expr in the select clause, it needs a select statement")
+	_, err = Select("*").Rewrite(toExpr(ClauseRoot))
+	assert.EqualError(t, err, "cannot replace a select statement with squirrel2.expr")
+
+	remove := func(n Node) (Sqlizer, error) {
+		if n.Clause == ClauseOperand {
+			return nil, nil
+		}
+		return n.Sqlizer, nil
+	}
+	_, err = Rewrite(Expr("a = ?", Expr("b")), remove)
+	assert.EqualError(t, err, "cannot remove squirrel2.expr from the operand clause")
+	s, err := Rewrite(Or{Expr("a"), Expr("b")}, remove)
+	assert.NoError(t, err)
+	assert.Equal(t, Or{}, s)
+
+	s, ok := AddWhere(Expr("x"), Eq{"a": 1})
+	assert.False(t, ok)
+	assert.Equal(t, Expr("x"), s)
+}